@@ -0,0 +1,88 @@
+package configo
+
+import (
+    "fmt"
+)
+
+// Alias makes old another name for the registered item new, so that a
+// renamed item keeps working in existing configuration files and command
+// lines.  The old name sets the new item, is reported as deprecated the first
+// time it is used, and is left out of default configuration files and usage
+// output.  The old name is still a flag of the set's flag.FlagSet, so the
+// flag package's own PrintDefaults lists it; print usage with WriteUsage or
+// PrintDefaults instead.  Alias panics if new is not registered or old is
+// already in use.
+func (c *ConfigoSet) Alias(old, new string) {
+    config, ok := c.formal[new]
+    if !ok {
+        panic(fmt.Sprintf("%s alias %s for unknown item %s", c.name, old, new))
+    }
+    if _, ok := c.shorthands[old]; ok || c.Lookup(old) != nil {
+        msg := fmt.Sprintf("%s flag redefined: %s", c.name, old)
+        fmt.Fprintln(c.out(), msg)
+        panic(msg)
+    }
+
+    if c.aliases == nil {
+        c.aliases = make(map[string]string)
+    }
+    c.aliases[old] = new
+    if _, ok := c.deprecated[old]; !ok {
+        c.deprecate(old, fmt.Sprintf("use %s instead", new))
+    }
+
+    if config.IsFlag {
+        c.flags.Var(config.Value, old, fmt.Sprintf("deprecated, use -%s", new))
+    }
+}
+
+// Alias makes old another name for the registered item new, so that a
+// renamed item keeps working in existing configuration files and command
+// lines.
+func Alias(old, new string) {
+    configuration.Alias(old, new)
+}
+
+// Deprecate marks the named item or alias as deprecated.  It keeps working,
+// but the first time it is used a warning including message is written,
+// along with the file and line or the command line where it was used.
+// Deprecated items are left out of default configuration files and usage
+// output.  Deprecate panics if there is no item or alias with the name.
+func (c *ConfigoSet) Deprecate(name, message string) {
+    if c.Lookup(name) == nil {
+        panic(fmt.Sprintf("%s cannot deprecate unknown item %s", c.name, name))
+    }
+    c.deprecate(name, message)
+}
+
+// Deprecate marks the named item or alias as deprecated.
+func Deprecate(name, message string) {
+    configuration.Deprecate(name, message)
+}
+
+func (c *ConfigoSet) deprecate(name, message string) {
+    if c.deprecated == nil {
+        c.deprecated = make(map[string]string)
+    }
+    c.deprecated[name] = message
+}
+
+// isDeprecated reports whether the named item or alias is deprecated.
+func (c *ConfigoSet) isDeprecated(name string) bool {
+    _, ok := c.deprecated[name]
+    return ok
+}
+
+// warnDeprecated writes a warning the first time a deprecated name is used.
+// The location says where it was used.
+func (c *ConfigoSet) warnDeprecated(name, location string) {
+    message, ok := c.deprecated[name]
+    if !ok || c.warned[name] {
+        return
+    }
+    if c.warned == nil {
+        c.warned = make(map[string]bool)
+    }
+    c.warned[name] = true
+    fmt.Fprintf(c.out(), "warning: %s: %s is deprecated: %s\n", location, name, message)
+}
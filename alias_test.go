@@ -0,0 +1,64 @@
+package configo
+
+import (
+    "bytes"
+    "flag"
+    "io/ioutil"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestAliasesLeftOutOfHelp(t *testing.T) {
+    c := newTestCommandLine(t)
+    var host string
+    c.StringVar(&host, "dbhost", "localhost", "the database host")
+    c.Alias("db_host", "dbhost")
+    c.String("colour", "", "the colour of the output")
+    c.Deprecate("colour", "it is ignored")
+
+    var out bytes.Buffer
+    c.SetOutput(&out)
+    c.SetArguments([]string{"-db_host", "remote", "-h"})
+    if err := c.Parse(); err != flag.ErrHelp {
+        t.Fatalf("Parse(-h) = %v, want %v", err, flag.ErrHelp)
+    }
+    if host != "remote" {
+        t.Errorf("dbhost = %q, want %q set by its old name", host, "remote")
+    }
+
+    usage := out.String()
+    if !strings.Contains(usage, "-dbhost") {
+        t.Errorf("usage does not list -dbhost:\n%s", usage)
+    }
+    for _, unwanted := range []string{"db_host", "colour"} {
+        if strings.Contains(usage, unwanted) {
+            t.Errorf("usage lists %s:\n%s", unwanted, usage)
+        }
+    }
+}
+
+func TestAliasInConfigFile(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "rc")
+    if err := ioutil.WriteFile(path, []byte("port=81\ndb_host=remote\n\ndb_host=remote\n"), 0600); err != nil {
+        t.Fatal(err)
+    }
+    c := NewConfigoSet("test", 0, path)
+    var out bytes.Buffer
+    c.SetOutput(&out)
+    host := c.String("dbhost", "localhost", "the database host")
+    c.Int("port", 80, "the port")
+    c.Alias("db_host", "dbhost")
+    c.SetArguments([]string{})
+    if err := c.Parse(); err != nil {
+        t.Fatalf("Parse: %v", err)
+    }
+    if *host != "remote" || c.Lookup("dbhost").Source() != "file:"+path {
+        t.Errorf("dbhost %q from %q, want %q set in the file by its old name", *host, c.Lookup("dbhost").Source(), "remote")
+    }
+
+    warning := "warning: " + path + ":2: db_host is deprecated: use dbhost instead\n"
+    if out.String() != warning {
+        t.Errorf("warned\n%s\nwant once\n%s", out.String(), warning)
+    }
+}
@@ -0,0 +1,75 @@
+// Command configo-encrypt encrypts values for configuration files read by
+// configo, and writes the key files which decrypt them.
+//
+// Write a new key file with
+//
+//	configo-encrypt -key ~/.myprogkey -genkey
+//
+// and encrypt the value of an item with it, read from standard input,
+//
+//	configo-encrypt -key ~/.myprogkey -item password < password.txt
+//
+// which prints a value starting with "enc:" to use for that item in the
+// configuration file.  The value may also be given as an argument, but then
+// it is left in the shell history and shown to other users by ps.
+package main
+
+import (
+    "bufio"
+    "flag"
+    "fmt"
+    "os"
+    "strings"
+
+    "github.com/quincy/configo"
+)
+
+var (
+    keyFile = flag.String("key", "", "path to the key file")
+    genKey  = flag.Bool("genkey", false, "write a new key to the key file")
+    item    = flag.String("item", "", "name of the item the value is for, such as serve.password")
+)
+
+func main() {
+    flag.Usage = func() {
+        fmt.Fprintf(os.Stderr, "Usage: %s -key file [-genkey | -item name [value]]\n", os.Args[0])
+        flag.PrintDefaults()
+    }
+    flag.Parse()
+    if *keyFile == "" || flag.NArg() > 1 || *genKey && (flag.NArg() > 0 || *item != "") || !*genKey && *item == "" {
+        flag.Usage()
+        os.Exit(2)
+    }
+
+    if *genKey {
+        if err := configo.WriteKeyFile(*keyFile); err != nil {
+            fatal(err)
+        }
+        return
+    }
+
+    key, err := configo.ReadKeyFile(*keyFile)
+    if err != nil {
+        fatal(err)
+    }
+
+    value := flag.Arg(0)
+    if flag.NArg() == 0 {
+        line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+        if err != nil && line == "" {
+            fatal(err)
+        }
+        value = strings.TrimRight(line, "\r\n")
+    }
+
+    encrypted, err := configo.Encrypt(key, *item, value)
+    if err != nil {
+        fatal(err)
+    }
+    fmt.Println(encrypted)
+}
+
+func fatal(err error) {
+    fmt.Fprintln(os.Stderr, "configo-encrypt:", err)
+    os.Exit(1)
+}
@@ -0,0 +1,169 @@
+package configo
+
+import (
+    "flag"
+    "fmt"
+)
+
+// Command is a program, or one of its subcommands such as "serve" in
+// "tool serve".  Each command has its own configuration items, registered
+// with the methods of its ConfigoSet, and also accepts the flags of the
+// commands above it on its command line.  The items of a subcommand are read
+// from its section of the shared configuration file, so "port" of "tool
+// serve" is set by port in the [serve] section, or by serve.port.
+type Command struct {
+    *ConfigoSet
+
+    // Name is the name of the command on the command line.
+    Name string
+
+    // Short is a one line description shown in usage output.
+    Short string
+
+    // Run is called with the remaining command line arguments after the
+    // command line and configuration file have been parsed.  A command
+    // without Run requires one of its subcommands.
+    Run func(cmd *Command, args []string) error
+
+    parent   *Command
+    commands []*Command
+}
+
+// rootCommand is the command behind AddCommand and Execute, which uses the
+// default set.
+var rootCommand = newCommand(configuration, baseProgName, nil)
+
+// NewCommand returns a new top level command with the specified name, error
+// handling property and configuration file path.
+func NewCommand(name string, errorHandling flag.ErrorHandling, path string) *Command {
+    cmd := newCommand(NewConfigoSet(name, errorHandling, path), name, nil)
+    cmd.Usage = cmd.PrintUsage
+    return cmd
+}
+
+func newCommand(c *ConfigoSet, name string, parent *Command) *Command {
+    return &Command{ConfigoSet: c, Name: name, parent: parent}
+}
+
+// AddCommand adds a subcommand with the given name, description and
+// function to run.  AddCommand panics if cmd already has a subcommand with
+// the name.
+func (cmd *Command) AddCommand(name, short string, run func(cmd *Command, args []string) error) *Command {
+    if cmd.lookupCommand(name) != nil {
+        msg := fmt.Sprintf("%s command redefined: %s", cmd.name, name)
+        fmt.Fprintln(cmd.out(), msg)
+        panic(msg)
+    }
+
+    c := NewConfigoSet(cmd.name+" "+name, cmd.errorHandling, "")
+    c.parent = cmd.ConfigoSet
+    if cmd.ConfigoSet.commands == nil {
+        cmd.ConfigoSet.commands = make(map[string]*ConfigoSet)
+    }
+    cmd.ConfigoSet.commands[name] = c
+
+    sub := newCommand(c, name, cmd)
+    sub.Short = short
+    sub.Run = run
+    sub.Usage = sub.PrintUsage
+    cmd.commands = append(cmd.commands, sub)
+
+    // A program gains per-command usage with its first subcommand.
+    if cmd.Usage == nil {
+        cmd.Usage = cmd.PrintUsage
+    }
+    return sub
+}
+
+// AddCommand adds a subcommand of the program with the given name,
+// description and function to run.
+func AddCommand(name, short string, run func(cmd *Command, args []string) error) *Command {
+    return rootCommand.AddCommand(name, short, run)
+}
+
+// Execute parses the command line, finds the subcommand named by the leading
+// arguments, reads the configuration file and runs the subcommand with the
+// remaining arguments.  Each command's flags are accepted after its name
+// and those of its subcommands.
+func (cmd *Command) Execute() error {
+    if err := cmd.parseFlags(); err != nil {
+        return err
+    }
+
+    target := cmd
+    for target.NArg() > 0 {
+        sub := target.lookupCommand(target.Arg(0))
+        if sub == nil {
+            break
+        }
+        sub.arguments = target.Args()[1:]
+        sub.syntax = target.syntax
+        if err := sub.parseFlags(); err != nil {
+            return err
+        }
+        target = sub
+    }
+
+    if err := cmd.parseFiles(); err != nil {
+        return err
+    }
+
+    if target.Run == nil {
+        if target.NArg() > 0 {
+            return target.failf(fmt.Errorf("unknown command %q for %s", target.Arg(0), target.name))
+        }
+        return target.failf(fmt.Errorf("%s requires a command", target.name))
+    }
+    return target.Run(target, target.Args())
+}
+
+// Execute parses the command line and configuration file and runs the
+// subcommand named on the command line.
+func Execute() error {
+    return rootCommand.Execute()
+}
+
+// lookupCommand returns the named subcommand, returning nil if none exists.
+func (cmd *Command) lookupCommand(name string) *Command {
+    for _, sub := range cmd.commands {
+        if sub.Name == name {
+            return sub
+        }
+    }
+    return nil
+}
+
+// PrintUsage prints the usage message of the command: its description, its
+// subcommands, its flags and those of the commands above it.
+func (cmd *Command) PrintUsage() {
+    w := cmd.out()
+    if len(cmd.commands) > 0 {
+        fmt.Fprintf(w, "Usage: %s [flags] command [arguments]\n", cmd.name)
+    } else if synopsis := cmd.argumentsSynopsis(); synopsis != "" {
+        fmt.Fprintf(w, "Usage: %s [flags] %s\n", cmd.name, synopsis)
+    } else {
+        fmt.Fprintf(w, "Usage: %s [flags] [arguments]\n", cmd.name)
+    }
+    if cmd.Short != "" {
+        fmt.Fprintf(w, "\n%s\n", cmd.Short)
+    }
+
+    if len(cmd.commands) > 0 {
+        width := 0
+        for _, sub := range cmd.commands {
+            if len(sub.Name) > width {
+                width = len(sub.Name)
+            }
+        }
+        fmt.Fprintf(w, "\nCommands:\n")
+        for _, sub := range cmd.commands {
+            fmt.Fprintf(w, "  %-*s  %s\n", width, sub.Name, sub.Short)
+        }
+    }
+
+    cmd.executeUsage(w, "arguments", "")
+    cmd.executeUsage(w, "flags", "Flags")
+    for p := cmd.parent; p != nil; p = p.parent {
+        p.executeUsage(w, "flags", "Flags of "+p.name)
+    }
+}
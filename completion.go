@@ -0,0 +1,365 @@
+package configo
+
+import (
+    "bufio"
+    "errors"
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "sort"
+    "strings"
+)
+
+// ErrCompletion is returned by Parse after it has written the completion
+// script asked for with -completion, unless the set exits on error, in which
+// case the program exits.
+var ErrCompletion = errors.New("configo: completion script written")
+
+// The name of the command line flag added by EnableCompletion.
+const completionFlagName = "completion"
+
+// SetChoices sets the values the named item may take, which are completed by
+// the scripts from GenerateCompletion.  The values are not checked.
+func (c *ConfigoSet) SetChoices(name string, choices ...string) {
+    config, ok := c.formal[name]
+    if !ok {
+        panic(fmt.Sprintf("%s choices for unknown item %s", c.name, name))
+    }
+    config.Choices = choices
+}
+
+// SetChoices sets the values the named item may take, which are completed by
+// the scripts from GenerateCompletion.
+func SetChoices(name string, choices ...string) {
+    configuration.SetChoices(name, choices...)
+}
+
+// MarkPaths marks the named items as file paths, whose values are completed
+// with file names by the scripts from GenerateCompletion.
+func (c *ConfigoSet) MarkPaths(names ...string) {
+    for _, name := range names {
+        config, ok := c.formal[name]
+        if !ok {
+            panic(fmt.Sprintf("%s path for unknown item %s", c.name, name))
+        }
+        config.IsPath = true
+    }
+}
+
+// MarkPaths marks the named items as file paths, whose values are completed
+// with file names by the scripts from GenerateCompletion.
+func MarkPaths(names ...string) {
+    configuration.MarkPaths(names...)
+}
+
+// EnableCompletion adds the hidden command line flag -completion, which makes
+// Parse write the completion script for the named shell to standard output
+// and exit, as in
+//
+//	source <(prog -completion bash)
+func (c *ConfigoSet) EnableCompletion() {
+    c.StringFlagVar(&c.completion, completionFlagName, "", "write the completion script for a shell")
+    c.formal[completionFlagName].Hidden = true
+}
+
+// EnableCompletion adds the hidden command line flag -completion, which makes
+// Parse write the completion script for the named shell and exit.
+func EnableCompletion() {
+    configuration.EnableCompletion()
+}
+
+// writeCompletion writes the completion script asked for with -completion.
+func (c *ConfigoSet) writeCompletion() error {
+    if err := c.GenerateCompletion(c.completion, os.Stdout); err != nil {
+        return c.failf(err)
+    }
+    if c.errorHandling == flag.ExitOnError {
+        os.Exit(0)
+    }
+    return ErrCompletion
+}
+
+// GenerateCompletion writes a completion script for shell, which is "bash",
+// "zsh" or "fish", to w.  The script completes the flags, the subcommands,
+// the choices of items set with SetChoices, and file names for items marked
+// with MarkPaths.  Boolean flags take no value, but true and false are
+// completed after -flag=.
+func (c *ConfigoSet) GenerateCompletion(shell string, w io.Writer) error {
+    b := bufio.NewWriter(w)
+    switch shell {
+    case "bash":
+        c.writeBashCompletion(b)
+    case "zsh":
+        // zsh runs the bash script through its bash compatibility layer.
+        fmt.Fprintf(b, "#compdef %s\n", c.name)
+        fmt.Fprintf(b, "autoload -U +X bashcompinit && bashcompinit\n")
+        c.writeBashCompletion(b)
+    case "fish":
+        c.writeFishCompletion(b)
+    default:
+        return fmt.Errorf("no completion for shell %q", shell)
+    }
+    return b.Flush()
+}
+
+// GenerateCompletion writes a completion script for shell, which is "bash",
+// "zsh" or "fish", to w.
+func GenerateCompletion(shell string, w io.Writer) error {
+    return configuration.GenerateCompletion(shell, w)
+}
+
+// completionCommand is a command, or subcommand, of a completion script.
+type completionCommand struct {
+    path     []string // the names of the subcommands leading to it
+    set      *ConfigoSet
+    commands []string // the names of its subcommands
+}
+
+// pathString returns the path of the command in a completion script, such as
+// "/serve/run", or "" for the program itself.
+func (cmd completionCommand) pathString() string {
+    if len(cmd.path) == 0 {
+        return ""
+    }
+    return "/" + strings.Join(cmd.path, "/")
+}
+
+// completionCommands returns c and its subcommands, depth first.
+func (c *ConfigoSet) completionCommands(path []string) []completionCommand {
+    cmd := completionCommand{path: path, set: c}
+    for name := range c.commands {
+        cmd.commands = append(cmd.commands, name)
+    }
+    sort.Strings(cmd.commands)
+
+    cmds := []completionCommand{cmd}
+    for _, name := range cmd.commands {
+        sub := append(append([]string{}, path...), name)
+        cmds = append(cmds, c.commands[name].completionCommands(sub)...)
+    }
+    return cmds
+}
+
+// completionFlags returns the flags accepted by set, including those of its
+// parent sets, leaving out hidden ones.
+func completionFlags(set *ConfigoSet) []*Configo {
+    var configs []*Configo
+    for ; set != nil; set = set.parent {
+        set.VisitAll(func(config *Configo) {
+            if config.IsFlag && !config.Hidden && !set.isDeprecated(config.Name) {
+                configs = append(configs, config)
+            }
+        })
+    }
+    return configs
+}
+
+// completionSpellings returns the ways config may be written on the command
+// line with the syntax of c.
+func (c *ConfigoSet) completionSpellings(config *Configo) []string {
+    long := "-"
+    if c.syntax == GNUSyntax && len(config.Name) > 1 {
+        long = "--"
+    }
+    spellings := []string{long + config.Name}
+    if config.Shorthand != "" {
+        spellings = append(spellings, "-"+config.Shorthand)
+    }
+    if c.syntax == GNUSyntax && isBoolFlag(config) && len(config.Name) > 1 {
+        spellings = append(spellings, "--no-"+config.Name)
+    }
+    return spellings
+}
+
+// completionFunc returns the name of the shell function for the program.
+func (c *ConfigoSet) completionFunc() string {
+    return "_" + strings.Map(func(r rune) rune {
+        if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+            return r
+        }
+        return '_'
+    }, c.name) + "_completion"
+}
+
+func (c *ConfigoSet) writeBashCompletion(b *bufio.Writer) {
+    cmds := c.completionCommands(nil)
+    fn := c.completionFunc()
+
+    fmt.Fprintf(b, "# bash completion for %s\n", c.name)
+    fmt.Fprintf(b, "%s() {\n", fn)
+    fmt.Fprintf(b, "    local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+    fmt.Fprintf(b, "    local path=\"\" i\n")
+
+    // Find the subcommand being completed.
+    if len(cmds) > 1 {
+        var paths []string
+        for _, cmd := range cmds[1:] {
+            paths = append(paths, shellQuote(cmd.pathString()))
+        }
+        fmt.Fprintf(b, "    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+        fmt.Fprintf(b, "        case \"$path/${COMP_WORDS[i]}\" in\n")
+        fmt.Fprintf(b, "        %s) path=\"$path/${COMP_WORDS[i]}\" ;;\n", strings.Join(paths, "|"))
+        fmt.Fprintf(b, "        esac\n")
+        fmt.Fprintf(b, "    done\n")
+    }
+
+    // Complete the value of a boolean flag written as -flag=value, which is
+    // one word, or three when the shell breaks words at "=".
+    fmt.Fprintf(b, "    local flag=\"\" value=\"$cur\" prefix=\"\"\n")
+    fmt.Fprintf(b, "    if [[ \"$prev\" == \"=\" && $COMP_CWORD -ge 2 ]]; then\n")
+    fmt.Fprintf(b, "        flag=\"${COMP_WORDS[COMP_CWORD-2]}\"\n")
+    fmt.Fprintf(b, "    elif [[ \"$cur\" == -*=* ]]; then\n")
+    fmt.Fprintf(b, "        flag=\"${cur%%%%=*}\" value=\"${cur#*=}\" prefix=\"${cur%%%%=*}=\"\n")
+    fmt.Fprintf(b, "    fi\n")
+    fmt.Fprintf(b, "    case \"$path:$flag\" in\n")
+    for _, cmd := range cmds {
+        var patterns []string
+        for _, config := range completionFlags(cmd.set) {
+            if !isBoolFlag(config) {
+                continue
+            }
+            for _, spelling := range c.completionSpellings(config) {
+                if !strings.HasPrefix(spelling, "--no-") {
+                    patterns = append(patterns, shellQuote(cmd.pathString()+":"+spelling))
+                }
+            }
+        }
+        if len(patterns) > 0 {
+            fmt.Fprintf(b, "    %s)\n", strings.Join(patterns, "|"))
+            fmt.Fprintf(b, "        COMPREPLY=($(compgen -P \"$prefix\" -W 'true false' -- \"$value\"))\n")
+            fmt.Fprintf(b, "        return ;;\n")
+        }
+    }
+    fmt.Fprintf(b, "    esac\n")
+
+    // Complete the value of the previous flag.
+    fmt.Fprintf(b, "    case \"$path:$prev\" in\n")
+    for _, cmd := range cmds {
+        path := cmd.pathString()
+        for _, config := range completionFlags(cmd.set) {
+            if isBoolFlag(config) {
+                continue
+            }
+            var patterns []string
+            for _, spelling := range c.completionSpellings(config) {
+                patterns = append(patterns, shellQuote(path+":"+spelling))
+            }
+            fmt.Fprintf(b, "    %s)\n", strings.Join(patterns, "|"))
+            switch {
+            case len(config.Choices) > 0:
+                fmt.Fprintf(b, "        COMPREPLY=($(compgen -W %s -- \"$cur\"))\n", shellQuote(strings.Join(config.Choices, " ")))
+            case config.IsPath:
+                fmt.Fprintf(b, "        COMPREPLY=($(compgen -f -- \"$cur\"))\n")
+            default:
+                fmt.Fprintf(b, "        COMPREPLY=()\n")
+            }
+            fmt.Fprintf(b, "        return ;;\n")
+        }
+    }
+    fmt.Fprintf(b, "    esac\n")
+
+    // Complete the flags and subcommands.
+    fmt.Fprintf(b, "    case \"$path\" in\n")
+    for _, cmd := range cmds {
+        path := cmd.pathString()
+        words := append([]string{}, cmd.commands...)
+        for _, config := range completionFlags(cmd.set) {
+            words = append(words, c.completionSpellings(config)...)
+        }
+        fmt.Fprintf(b, "    %s)\n", shellQuote(path))
+        fmt.Fprintf(b, "        COMPREPLY=($(compgen -W %s -- \"$cur\")) ;;\n", shellQuote(strings.Join(words, " ")))
+    }
+    fmt.Fprintf(b, "    esac\n")
+    fmt.Fprintf(b, "}\n")
+    fmt.Fprintf(b, "complete -o default -F %s %s\n", fn, c.name)
+}
+
+func (c *ConfigoSet) writeFishCompletion(b *bufio.Writer) {
+    cmds := c.completionCommands(nil)
+    fn := c.completionFunc()
+
+    fmt.Fprintf(b, "# fish completion for %s\n", c.name)
+    fmt.Fprintf(b, "complete -c %s -f\n", c.name)
+
+    // The function prints the path of the subcommand being completed, such as
+    // "/serve/run", so that subcommands with the same name at different
+    // places in the tree are told apart.
+    fmt.Fprintf(b, "function %s\n", fn)
+    fmt.Fprintf(b, "    set -l path \"\"\n")
+    if len(cmds) > 1 {
+        var paths []string
+        for _, cmd := range cmds[1:] {
+            paths = append(paths, shellQuote(cmd.pathString()))
+        }
+        fmt.Fprintf(b, "    for word in (commandline -opc)[2..-1]\n")
+        fmt.Fprintf(b, "        switch \"$path/$word\"\n")
+        fmt.Fprintf(b, "        case %s\n", strings.Join(paths, " "))
+        fmt.Fprintf(b, "            set path \"$path/$word\"\n")
+        fmt.Fprintf(b, "        end\n")
+        fmt.Fprintf(b, "    end\n")
+    }
+    fmt.Fprintf(b, "    echo \"$path\"\n")
+    fmt.Fprintf(b, "end\n")
+
+    for _, cmd := range cmds {
+        path := cmd.pathString()
+
+        // The subcommands are offered at the exact path of their parent.
+        at := fmt.Sprintf("test (%s) = %s", fn, shellQuote(path))
+        for _, name := range cmd.commands {
+            fmt.Fprintf(b, "complete -c %s -n %s -a %s\n", c.name, shellQuote(at), shellQuote(name))
+        }
+
+        // The flags are offered at the path of their set and below it.
+        within, condition := "", ""
+        if path != "" {
+            within = fmt.Sprintf("string match -q -- %s (%s); or string match -q -- %s (%s)", shellQuote(path), fn, shellQuote(path+"/*"), fn)
+            condition = " -n " + shellQuote(within)
+        }
+        var configs []*Configo
+        cmd.set.VisitAll(func(config *Configo) {
+            if config.IsFlag && !config.Hidden && !cmd.set.isDeprecated(config.Name) {
+                configs = append(configs, config)
+            }
+        })
+        for _, config := range configs {
+            fmt.Fprintf(b, "complete -c %s%s", c.name, condition)
+            for _, spelling := range c.completionSpellings(config) {
+                switch {
+                case strings.HasPrefix(spelling, "--"):
+                    fmt.Fprintf(b, " -l %s", shellQuote(spelling[2:]))
+                case len(spelling) == 2:
+                    fmt.Fprintf(b, " -s %s", shellQuote(spelling[1:]))
+                default:
+                    fmt.Fprintf(b, " -o %s", shellQuote(spelling[1:]))
+                }
+            }
+            switch {
+            case isBoolFlag(config):
+            case len(config.Choices) > 0:
+                fmt.Fprintf(b, " -x -a %s", shellQuote(strings.Join(config.Choices, " ")))
+            case config.IsPath:
+                fmt.Fprintf(b, " -r -F")
+            default:
+                fmt.Fprintf(b, " -x")
+            }
+            fmt.Fprintf(b, " -d %s\n", shellQuote(config.Usage))
+
+            // A boolean flag may be given a value as -flag=value.
+            if isBoolFlag(config) {
+                spelling := c.completionSpellings(config)[0]
+                typing := fmt.Sprintf("string match -q -- %s (commandline -ct)", shellQuote(spelling+"=*"))
+                if within != "" {
+                    typing = "begin; " + within + "; end; and " + typing
+                }
+                fmt.Fprintf(b, "complete -c %s -n %s -a %s\n", c.name, shellQuote(typing), shellQuote(spelling+"=true "+spelling+"=false"))
+            }
+        }
+    }
+}
+
+// shellQuote quotes s for the shell with single quotes.
+func shellQuote(s string) string {
+    return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
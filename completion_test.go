@@ -0,0 +1,87 @@
+package configo
+
+import (
+    "bytes"
+    "fmt"
+    "io/ioutil"
+    "os/exec"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+// newCompletionTest returns a command tree with two subcommands both named
+// run, under a and b.
+func newCompletionTest() *Command {
+    root := NewCommand("tool", 0, "")
+    root.Bool("verbose", false, "print more")
+    root.String("level", "info", "the log level")
+    root.SetChoices("level", "debug", "info")
+    root.EnableCompletion()
+    a := root.AddCommand("a", "", nil)
+    a.AddCommand("run", "", nil).String("fast", "", "run fast")
+    b := root.AddCommand("b", "", nil)
+    b.AddCommand("run", "", nil).String("slow", "", "run slow")
+    return root
+}
+
+func TestBashCompletion(t *testing.T) {
+    bash, err := exec.LookPath("bash")
+    if err != nil {
+        t.Skip("no bash")
+    }
+    var script bytes.Buffer
+    if err := newCompletionTest().GenerateCompletion("bash", &script); err != nil {
+        t.Fatal(err)
+    }
+    path := filepath.Join(t.TempDir(), "tool.bash")
+    if err := ioutil.WriteFile(path, script.Bytes(), 0600); err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        words string
+        want  string
+    }{
+        {`tool -`, "-level -verbose"},
+        {`tool ""`, "a b -level -verbose"},
+        {`tool -level ""`, "debug info"},
+        {`tool -verbose=`, "-verbose=true -verbose=false"},
+        {`tool -verbose = f`, "false"},
+        {`tool a run -`, "-fast -level -verbose"},
+        {`tool b run -`, "-slow -level -verbose"},
+        {`tool b r`, "run"},
+    }
+    for _, test := range tests {
+        words := strings.Fields(test.words)
+        code := fmt.Sprintf(". %s\nCOMP_WORDS=(%s); COMP_CWORD=%d; _tool_completion; echo \"${COMPREPLY[*]}\"",
+            path, test.words, len(words)-1)
+        out, err := exec.Command(bash, "-c", code).CombinedOutput()
+        if err != nil {
+            t.Fatalf("%s: %v\n%s", test.words, err, out)
+        }
+        if got := strings.TrimSpace(string(out)); got != test.want {
+            t.Errorf("%s: got %q, want %q", test.words, got, test.want)
+        }
+    }
+}
+
+func TestFishCompletionPaths(t *testing.T) {
+    var script bytes.Buffer
+    if err := newCompletionTest().GenerateCompletion("fish", &script); err != nil {
+        t.Fatal(err)
+    }
+    for _, line := range strings.Split(script.String(), "\n") {
+        switch {
+        case strings.Contains(line, "-o 'fast'") && !strings.Contains(line, "'/a/run'"):
+            t.Errorf("-fast is not limited to a run: %s", line)
+        case strings.Contains(line, "-o 'slow'") && !strings.Contains(line, "'/b/run'"):
+            t.Errorf("-slow is not limited to b run: %s", line)
+        case strings.Contains(line, "-o 'completion'"):
+            t.Errorf("the hidden flag is completed: %s", line)
+        }
+    }
+    if !strings.Contains(script.String(), "-verbose=true -verbose=false") {
+        t.Errorf("no values for -verbose:\n%s", script.String())
+    }
+}
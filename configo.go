@@ -33,14 +33,35 @@ Configuration files consist of lines of key/value pairs, delimited by '='.  The
 delimiter can be changed if needed by setting configo.SetDelimiter().  Blank lines
 and lines where the first non-whitespace character is '#' are ignored.
 Trailing comments are not allowed, however.
+
+Configuration files whose name ends in ".toml" are read and written as TOML
+instead.  Tables and dotted keys map to dotted item names, so the key "type" in
+the table [gopher] sets the item "gopher.type", and arrays are passed to the
+item as a single comma separated value.
+
+Configuration files whose name ends in ".yaml" or ".yml" are read as a subset
+of YAML: block mappings, sequences, scalars, quoted strings and comments.
+Nested mapping keys are joined with dots to form the item name, and sequences
+are passed to the item as a single comma separated value.
+
+Values are taken, from lowest to highest precedence, from the registered
+defaults, the embedded defaults set with SetEmbeddedDefaults, the configuration
+file and the command line.  The Source method of a Configo reports which one
+supplied its current value.
+
+Other formats can be added by implementing the Format interface and registering
+it with RegisterFormat.  The format is chosen by the extension of the
+configuration file, or explicitly with SetFormat.
 */
 package configo
 
 import (
+    "bytes"
     "errors"
     "flag"
     "fmt"
     "io"
+    "io/fs"
     "io/ioutil"
     "os"
     "os/user"
@@ -48,6 +69,7 @@ import (
     "sort"
     "strconv"
     "strings"
+    "text/template"
     "time"
 )
 
@@ -65,6 +87,35 @@ type ConfigoSet struct {
     output        io.Writer
     path          string
     delimiter     string
+    format        string
+    embedded      fs.FS
+    embeddedName  string
+    configFlags   bool
+    configPath    string
+    noConfig      bool
+    createPolicy  CreatePolicy
+    writeDefault  bool
+    writeOptions  WriteOptions
+    aliases       map[string]string
+    deprecated    map[string]string
+    warned        map[string]bool
+    shorthands    map[string]string
+    syntax        Syntax
+    flags         *flag.FlagSet
+    arguments     []string
+    args          []string
+    nflag         int
+    parent        *ConfigoSet
+    commands      map[string]*ConfigoSet
+    positionals   []*Argument
+    usageTemplate *template.Template
+    completion    string
+    keyFile       string
+    key           []byte
+    permissions   PermissionPolicy
+    profileFlags  bool
+    profile       string
+    profiles      map[string]bool
 }
 
 // Configo is a single configuration item registered to a ConfigoSet.
@@ -75,6 +126,13 @@ type Configo struct {
     DefaultValue string
     IsFlag       bool
     IsConfig     bool
+    Shorthand    string   // short command line name, or ""
+    Category     string   // groups the item in usage output
+    Choices      []string // the values offered by shell completion
+    IsPath       bool     // the value is a file path
+    Hidden       bool     // left out of usage output and documentation
+
+    source string
 }
 
 // -- bool Value
@@ -215,10 +273,22 @@ func (d *durationValue) String() string { return (*time.Duration)(d).String() }
 
 // The default set of configuration options.
 var baseProgName string = filepath.Base(os.Args[0])
-var configuration = NewConfigoSet(baseProgName, flag.ExitOnError, DefaultConfigPath())
+var configuration = newCommandLine()
+
+// newCommandLine returns the default set, whose flags are registered with the
+// flag package's command line so that flag.Args and friends keep working.
+// flag.Usage prints the usage message of the set, which leaves out hidden
+// items and old names, until the program sets its own.
+func newCommandLine() *ConfigoSet {
+    c := NewConfigoSet(baseProgName, flag.ExitOnError, DefaultConfigPath())
+    c.flags = flag.CommandLine
+    c.flags.Usage = c.usage
+    flag.Usage = c.printUsage
+    return c
+}
 
 // NewConfigoSet returns a new, empty configuration set with the specified name
-// and error handling property.
+// and error handling property.  Its flags are kept in its own flag.FlagSet.
 func NewConfigoSet(name string, errorHandling flag.ErrorHandling, path string) *ConfigoSet {
     c := &ConfigoSet{
         name:          name,
@@ -226,6 +296,8 @@ func NewConfigoSet(name string, errorHandling flag.ErrorHandling, path string) *
         delimiter:     "=",
         path:          path,
     }
+    c.flags = flag.NewFlagSet(name, errorHandling)
+    c.flags.Usage = c.usage
     return c
 }
 
@@ -247,41 +319,550 @@ func SetPath(path string) {
     configuration.path = path
 }
 
-// WriteDefaultConfig writes a config file to c.path which contains all of the
+// The names of the command line flags added by EnableConfigFlags.
+const (
+    configFlagName   = "config"
+    noConfigFlagName = "no-config"
+)
+
+// EnableConfigFlags adds the command line flags -config, which names the
+// configuration file to read instead of the default path, and -no-config,
+// which skips reading a configuration file at all.  Both are handled by Parse
+// before the configuration file is read.  A file named with -config must
+// exist; it is never created.  Use "-config -" to read standard input.
+func (c *ConfigoSet) EnableConfigFlags() {
+    c.configFlags = true
+    c.StringFlagVar(&c.configPath, configFlagName, c.path, "path to the configuration file")
+    c.BoolFlagVar(&c.noConfig, noConfigFlagName, false, "do not read a configuration file")
+    c.MarkPaths(configFlagName)
+}
+
+// EnableConfigFlags adds the command line flags -config and -no-config, which
+// choose the configuration file read by Parse.
+func EnableConfigFlags() {
+    configuration.EnableConfigFlags()
+}
+
+// WriteOptions control what WriteDefaultConfig and WriteDefaultConfigTo
+// write.
+type WriteOptions struct {
+    // IncludeFlagOnly includes the items which can only be given on the
+    // command line.
+    IncludeFlagOnly bool
+
+    // CommentOut comments out every line, so the written file documents the
+    // defaults without setting any of them.
+    CommentOut bool
+}
+
+// SetWriteOptions sets the options used when writing a default configuration
+// file.
+func (c *ConfigoSet) SetWriteOptions(opts WriteOptions) {
+    c.writeOptions = opts
+}
+
+// SetWriteOptions sets the options used when writing a default configuration
+// file.
+func SetWriteOptions(opts WriteOptions) {
+    configuration.SetWriteOptions(opts)
+}
+
+// WriteDefaultConfig writes a config file to path which contains all of the
 // defined configuration items with their default values, including usage
-// comments.
-func (c *ConfigoSet) WriteDefaultConfig(path string) (err error) {
-    fmt.Fprintln(c.out(), "Writing a default configuration file to", path)
+// comments.  The format is chosen by the extension of path.  The file is
+// written atomically with mode 0600 and its parent directories are created if
+// needed.
+func (c *ConfigoSet) WriteDefaultConfig(path string) error {
+    return writeFileAtomic(path, 0600, func(w io.Writer) error {
+        return c.writeDefaultConfig(w, path)
+    })
+}
+
+// WriteDefaultConfig writes a config file to path which contains all of the
+// defined configuration items with their default values.
+func WriteDefaultConfig(path string) error {
+    return configuration.WriteDefaultConfig(path)
+}
+
+// WriteDefaultConfigTo writes the default configuration to w in the format of
+// the configuration file.
+func (c *ConfigoSet) WriteDefaultConfigTo(w io.Writer) error {
+    return c.writeDefaultConfig(w, c.path)
+}
 
-    origOut := c.output
-    c.output, err = os.Create(c.path)
+// WriteDefaultConfigTo writes the default configuration to w in the format of
+// the configuration file.
+func WriteDefaultConfigTo(w io.Writer) error {
+    return configuration.WriteDefaultConfigTo(w)
+}
+
+// writeDefaultConfig writes the default configuration to w in the format used
+// for the configuration file named name.
+func (c *ConfigoSet) writeDefaultConfig(w io.Writer, name string) error {
+    format, err := c.formatFor(name)
     if err != nil {
-        return
+        return err
     }
 
-    fmt.Fprintf(c.out(), "# Default config file for %s\n", c.name)
-    fmt.Fprintf(c.out(), "# Written on %s\n\n", time.Now().Format(time.RFC822Z))
+    commenter, ok := format.(Commenter)
+    if !ok && c.writeOptions.CommentOut {
+        return fmt.Errorf("cannot comment out the default configuration: the format for %s has no comments", name)
+    }
+    if ok {
+        prefix := commenter.CommentPrefix()
+        fmt.Fprintf(w, "%s Default config file for %s\n", prefix, c.name)
+        fmt.Fprintf(w, "%s Written on %s\n\n", prefix, time.Now().Format(time.RFC822Z))
+    }
 
-    c.VisitAll(func(config *Configo) {
-        if config.IsConfig {
-            format := "# %s\n%s%s%s\n\n"
-            fmt.Fprintf(c.out(), format, config.Usage, config.Name, c.delimiter, config.DefaultValue)
+    configs := c.fileConfigs(c.writeOptions.IncludeFlagOnly)
+    if !c.writeOptions.CommentOut {
+        return format.Encode(w, configs)
+    }
+    return encodeCommented(w, format, commenter.CommentPrefix(), configs)
+}
+
+// encodeCommented encodes configs with format, commenting out every line which
+// is not already a comment.
+func encodeCommented(w io.Writer, format Format, prefix string, configs []*Configo) error {
+    var buf bytes.Buffer
+    if err := format.Encode(&buf, configs); err != nil {
+        return err
+    }
+    for _, line := range strings.SplitAfter(buf.String(), "\n") {
+        trimmed := strings.TrimSpace(line)
+        if trimmed != "" && !strings.HasPrefix(trimmed, prefix) {
+            line = prefix + line
+        }
+        if _, err := io.WriteString(w, line); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// SaveValue sets the named item to value and saves it in the configuration
+// file, changing only that item and preserving the comments, blank lines,
+// ordering and other items already there.  A missing item is added to the
+// file, and the file is created if it does not exist.  The format of the
+// configuration file must implement Editor.
+//
+// The items of subcommands are saved in the configuration file of the root
+// set, and may be named there by their section, as in "serve.port".
+func (c *ConfigoSet) SaveValue(name, value string) error {
+    root := c
+    for root.parent != nil {
+        root = root.parent
+    }
+    set, config, _ := root.lookupEntry(c.qualifiedName(name))
+    if config == nil {
+        return fmt.Errorf("no such configuration item %v", name)
+    }
+    if !config.IsConfig {
+        return fmt.Errorf("%s cannot be set in the configuration file", name)
+    }
+    path := root.path
+    switch path {
+    case "":
+        return errors.New("there is no configuration file to save the value in")
+    case "-":
+        return errors.New("cannot save a value to standard input")
+    }
+
+    format, err := root.formatFor(path)
+    if err != nil {
+        return err
+    }
+    editor, ok := format.(Editor)
+    if !ok {
+        return fmt.Errorf("the format for %s does not support editing", path)
+    }
+
+    perm := os.FileMode(0600)
+    content, err := ioutil.ReadFile(path)
+    if err != nil && !os.IsNotExist(err) {
+        return err
+    }
+    if info, err := os.Stat(path); err == nil {
+        perm = info.Mode().Perm()
+    }
+
+    // The editor finds the item by its name in the file.
+    qualified := *config
+    qualified.Name = set.qualifiedName(config.Name)
+
+    // Check the value on a copy, so that nothing changes unless the file is
+    // saved.  A user-defined Value cannot be copied, so it is set and then
+    // restored if the file cannot be saved.
+    old, oldSource := config.Value.String(), config.source
+    check := copyValue(config.Value)
+    if check == nil {
+        check = config.Value
+    }
+    if err = check.Set(value); err != nil {
+        return fmt.Errorf("invalid value for %s: %v", name, err)
+    }
+    if content, err = editor.Edit(content, &qualified, value); err == nil {
+        err = writeFileAtomic(path, perm, func(w io.Writer) error {
+            _, err := w.Write(content)
+            return err
+        })
+    }
+    if err != nil {
+        if check == config.Value {
+            config.Value.Set(old)
+            config.source = oldSource
         }
+        return fmt.Errorf("%s: %v", path, err)
+    }
+    if check == config.Value {
+        set.mark(config, sourceSet)
+        return nil
+    }
+    return set.set(config, value, sourceSet)
+}
+
+// copyValue returns a new Value of the same built in type as value, or nil if
+// value is user-defined.
+func copyValue(value flag.Value) flag.Value {
+    switch value.(type) {
+    case *boolValue:
+        return new(boolValue)
+    case *intValue:
+        return new(intValue)
+    case *int64Value:
+        return new(int64Value)
+    case *uintValue:
+        return new(uintValue)
+    case *uint64Value:
+        return new(uint64Value)
+    case *stringValue:
+        return new(stringValue)
+    case *float64Value:
+        return new(float64Value)
+    case *durationValue:
+        return new(durationValue)
+    case *secretValue:
+        return new(secretValue)
+    }
+    return nil
+}
+
+// SaveValue sets the named item to value and saves it in the configuration
+// file, preserving everything else in the file.
+func SaveValue(name, value string) error {
+    return configuration.SaveValue(name, value)
+}
+
+// UpgradeConfig adds every configuration item which is missing from the
+// configuration file, commented out and preceded by its usage text, so that
+// users of an existing file learn about items added since it was written.
+// Items already in the file, even commented out, are left alone and the rest
+// of the file is untouched.  If the format implements Editor the items are
+// added where they belong, otherwise they are added at the end of the file.
+// The format must implement Commenter.
+func (c *ConfigoSet) UpgradeConfig() error {
+    format, err := c.formatFor(c.path)
+    if err != nil {
+        return err
+    }
+    commenter, ok := format.(Commenter)
+    if !ok {
+        return fmt.Errorf("the format for %s has no comments", c.path)
+    }
+    prefix := commenter.CommentPrefix()
+
+    content, err := ioutil.ReadFile(c.path)
+    if err != nil {
+        return err
+    }
+    info, err := os.Stat(c.path)
+    if err != nil {
+        return err
+    }
+    if len(content) > 0 && content[len(content)-1] != '\n' {
+        content = append(content, '\n')
+    }
+
+    documented, err := documentedNames(format, prefix, content)
+    if err != nil {
+        return fmt.Errorf("%s: %v", c.path, err)
+    }
+
+    // An item set by an old name is already in the file.
+    for old, new := range c.aliases {
+        if documented[old] {
+            documented[new] = true
+        }
+    }
+
+    var missing []*Configo
+    for _, config := range c.fileConfigs(false) {
+        if !documented[config.Name] {
+            missing = append(missing, config)
+        }
+    }
+    if len(missing) == 0 {
+        return nil
+    }
+
+    if _, ok := format.(Editor); ok {
+        if content, err = insertCommented(format, prefix, content, missing); err != nil {
+            return fmt.Errorf("%s: %v", c.path, err)
+        }
+    } else {
+        for _, config := range missing {
+            var buf bytes.Buffer
+            if err = encodeCommented(&buf, format, prefix, []*Configo{config}); err != nil {
+                return fmt.Errorf("%s: %v", c.path, err)
+            }
+            content = append(append(content, '\n'), buf.Bytes()...)
+        }
+    }
+
+    return writeFileAtomic(c.path, info.Mode().Perm(), func(w io.Writer) error {
+        _, err := w.Write(content)
+        return err
     })
+}
 
-    c.output = origOut
-    return
+// UpgradeConfig adds every configuration item which is missing from the
+// configuration file, commented out and preceded by its usage text.
+func UpgradeConfig() error {
+    return configuration.UpgradeConfig()
+}
+
+// documentedNames returns the names of the items set in content, including
+// those on lines which have been commented out.
+func documentedNames(format Format, prefix string, content []byte) (map[string]bool, error) {
+    entries, err := format.Decode(bytes.NewReader(content))
+    if err != nil {
+        return nil, err
+    }
+    names := make(map[string]bool)
+    for _, e := range entries {
+        names[e.Name] = true
+    }
+
+    // Read the commented out lines again, uncommented.
+    lines, was := uncommentLines(format, prefix, strings.SplitAfter(string(content), "\n"))
+    var uncommented []string
+    for i, line := range lines {
+        if was[i] {
+            uncommented = append(uncommented, line)
+        }
+    }
+
+    entries, err = format.Decode(strings.NewReader(strings.Join(lines, "")))
+    if err != nil {
+        // Fall back to the commented out lines on their own.
+        entries, _ = format.Decode(strings.NewReader(strings.Join(uncommented, "")))
+    }
+    for _, e := range entries {
+        names[e.Name] = true
+    }
+    return names, nil
+}
+
+// uncommentLines returns lines with every comment line that is valid on its
+// own uncommented, which leaves the usage text and other prose commented, and
+// reports which lines were.
+func uncommentLines(format Format, prefix string, lines []string) ([]string, []bool) {
+    uncommented := make([]string, len(lines))
+    was := make([]bool, len(lines))
+    for i, line := range lines {
+        uncommented[i] = line
+        text := strings.TrimLeft(line, " \t")
+        if !strings.HasPrefix(text, prefix) {
+            continue
+        }
+        line = line[:len(line)-len(text)] + text[len(prefix):]
+        if _, err := format.Decode(strings.NewReader(line)); err == nil {
+            uncommented[i] = line
+            was[i] = true
+        }
+    }
+    return uncommented, was
+}
+
+// insertCommented adds configs to content where the editor of format would
+// add them, but commented out and each preceded by its usage text.  They are
+// added one by one to a copy of content in which the lines already added, and
+// the items commented out before, are not commented out, so that items in the
+// same table or mapping share it even when it is commented out.
+func insertCommented(format Format, prefix string, content []byte, configs []*Configo) ([]byte, error) {
+    editor := format.(Editor)
+    lines := strings.SplitAfter(string(content), "\n")
+
+    // restore holds what each line is to be at the end, or "" to keep it.
+    restore := make([]string, len(lines))
+    uncommented, was := uncommentLines(format, prefix, lines)
+    if _, err := format.Decode(strings.NewReader(strings.Join(uncommented, ""))); err == nil {
+        for i := range lines {
+            if was[i] {
+                restore[i] = lines[i]
+            }
+        }
+        lines = uncommented
+    }
+
+    for _, config := range configs {
+        edited, err := editor.Edit([]byte(strings.Join(lines, "")), config, config.DefaultValue)
+        if err != nil {
+            return nil, err
+        }
+
+        // Edit only inserts whole lines, which are the lines between those
+        // the two versions have in common at the start and at the end.
+        after := strings.SplitAfter(string(edited), "\n")
+        start := 0
+        for start < len(lines) && lines[start] == after[start] {
+            start++
+        }
+        end := len(after)
+        for end > start && len(lines)-(len(after)-end) > start && lines[len(lines)-(len(after)-end)-1] == after[end-1] {
+            end--
+        }
+        suffix := len(lines) - (len(after) - end)
+
+        inserted := after[start:end]
+        last := len(inserted) - 1
+        for last > 0 && strings.TrimSpace(inserted[last]) == "" {
+            last--
+        }
+        var news, restores []string
+        for i, line := range inserted {
+            text := strings.TrimLeft(line, " \t")
+            if i == last {
+                indent := line[:len(line)-len(text)]
+                news = append(news, indent+prefix+" "+config.Usage+"\n")
+                restores = append(restores, "")
+            }
+            news = append(news, line)
+            if text != "" && text != "\n" {
+                restores = append(restores, prefix+line)
+            } else {
+                restores = append(restores, "")
+            }
+        }
+
+        lines = append(append(append([]string{}, after[:start]...), news...), after[end:]...)
+        restore = append(append(append([]string{}, restore[:start]...), restores...), restore[suffix:]...)
+    }
+
+    for i := range lines {
+        if restore[i] != "" {
+            lines[i] = restore[i]
+        }
+    }
+    return []byte(strings.Join(lines, "")), nil
+}
+
+// writeFileAtomic creates the file at path with the content written by write.
+// The content is written to a temporary file in the same directory which is
+// then renamed to path, so readers never see a partially written file.
+func writeFileAtomic(path string, perm os.FileMode, write func(io.Writer) error) (err error) {
+    if path == "" {
+        return errors.New("no file name to write to")
+    }
+    dir, base := filepath.Split(path)
+    if dir == "" {
+        dir = "."
+    }
+    if err = os.MkdirAll(dir, 0700); err != nil {
+        return
+    }
+
+    tmp, err := ioutil.TempFile(dir, "."+base+".tmp")
+    if err != nil {
+        return
+    }
+    defer func() {
+        if err != nil {
+            tmp.Close()
+            os.Remove(tmp.Name())
+        }
+    }()
+
+    if err = tmp.Chmod(perm); err != nil {
+        return
+    }
+    if err = write(tmp); err != nil {
+        return
+    }
+    if err = tmp.Sync(); err != nil {
+        return
+    }
+    if err = tmp.Close(); err != nil {
+        return
+    }
+    return os.Rename(tmp.Name(), path)
+}
+
+// CreatePolicy controls whether Parse writes a default configuration file when
+// the configuration file does not exist.
+type CreatePolicy int
+
+// These constants cause Parse to behave as described if the configuration
+// file does not exist.
+const (
+    CreateIfMissing CreatePolicy = iota // Write a default configuration file.
+    NeverCreate                         // Carry on with the default values.
+    CreateOnFlag                        // Write one only if -write-default-config is given.
+)
+
+// The name of the command line flag added by the CreateOnFlag policy.
+const writeDefaultConfigFlagName = "write-default-config"
+
+// SetCreatePolicy sets whether Parse writes a default configuration file when
+// the configuration file does not exist.  The default is CreateIfMissing.
+// The CreateOnFlag policy adds the -write-default-config command line flag,
+// so it must be set before Parse is called.
+func (c *ConfigoSet) SetCreatePolicy(policy CreatePolicy) {
+    c.createPolicy = policy
+    if policy == CreateOnFlag && c.Lookup(writeDefaultConfigFlagName) == nil {
+        c.BoolFlagVar(&c.writeDefault, writeDefaultConfigFlagName, false, "write a default configuration file if there is none")
+    }
+}
+
+// SetCreatePolicy sets whether Parse writes a default configuration file when
+// the configuration file does not exist.
+func SetCreatePolicy(policy CreatePolicy) {
+    configuration.SetCreatePolicy(policy)
+}
+
+// shouldCreate reports whether a missing configuration file should be created
+// under the create policy.
+func (c *ConfigoSet) shouldCreate() bool {
+    switch c.createPolicy {
+    case CreateIfMissing:
+        return true
+    case CreateOnFlag:
+        return c.writeDefault
+    }
+    return false
 }
 
 // Arg returns the i'th command-line argument. Arg(0) is the first remaining
 // argument after flags have been processed.
 func (c *ConfigoSet) Arg(i int) string {
-    return flag.Arg(i)
+    if i < 0 || i >= len(c.args) {
+        return ""
+    }
+    return c.args[i]
 }
 
 // Args returns the non-flag command-line arguments.
 func (c *ConfigoSet) Args() []string {
-    return flag.Args()
+    return c.args
+}
+
+// SetArguments sets the command line arguments parsed by Parse, without the
+// program name, in place of os.Args[1:].
+func (c *ConfigoSet) SetArguments(arguments []string) {
+    if arguments == nil {
+        arguments = []string{}
+    }
+    c.arguments = arguments
 }
 
 // -- User functions for registering bool flags
@@ -296,7 +877,6 @@ func (c *ConfigoSet) BoolVar(p *bool, name string, value bool, usage string) {
     isFlag := true
     isConfig := true
     c.Var(newBoolValue(value, p), name, usage, isFlag, isConfig)
-    flag.BoolVar(p, name, value, usage)
 }
 
 // BoolConfigVar defines a bool config item with specified name, default value,
@@ -319,7 +899,6 @@ func (c *ConfigoSet) BoolFlagVar(p *bool, name string, value bool, usage string)
     isFlag := true
     isConfig := false
     c.Var(newBoolValue(value, p), name, usage, isFlag, isConfig)
-    flag.BoolVar(p, name, value, usage)
 }
 
 // BoolVar defines a bool config item with specified name, default value, and
@@ -332,7 +911,6 @@ func BoolVar(p *bool, name string, value bool, usage string) {
     isFlag := true
     isConfig := true
     configuration.Var(newBoolValue(value, p), name, usage, isFlag, isConfig)
-    flag.BoolVar(p, name, value, usage)
 }
 
 // BoolConfigVar defines a bool config item with specified name, default value, and
@@ -355,7 +933,6 @@ func BoolFlagVar(p *bool, name string, value bool, usage string) {
     isFlag := true
     isConfig := false
     configuration.Var(newBoolValue(value, p), name, usage, isFlag, isConfig)
-    flag.BoolVar(p, name, value, usage)
 }
 
 // Bool defines a bool configuration option with specified name, default value,
@@ -422,7 +999,6 @@ func (c *ConfigoSet) IntVar(p *int, name string, value int, usage string) {
     isFlag := true
     isConfig := true
     c.Var(newIntValue(value, p), name, usage, isFlag, isConfig)
-    flag.IntVar(p, name, value, usage)
 }
 
 // IntFlagVar defines an int flag with specified name, default value, and usage string.
@@ -431,7 +1007,6 @@ func (c *ConfigoSet) IntFlagVar(p *int, name string, value int, usage string) {
     isFlag := true
     isConfig := false
     c.Var(newIntValue(value, p), name, usage, isFlag, isConfig)
-    flag.IntVar(p, name, value, usage)
 }
 
 // IntConfigVar defines an int flag with specified name, default value, and usage string.
@@ -440,7 +1015,6 @@ func (c *ConfigoSet) IntConfigVar(p *int, name string, value int, usage string)
     isFlag := false
     isConfig := true
     c.Var(newIntValue(value, p), name, usage, isFlag, isConfig)
-    flag.IntVar(p, name, value, usage)
 }
 
 // IntVar defines an int flag with specified name, default value, and usage string.
@@ -449,7 +1023,6 @@ func IntVar(p *int, name string, value int, usage string) {
     isFlag := true
     isConfig := true
     configuration.Var(newIntValue(value, p), name, usage, isFlag, isConfig)
-    flag.IntVar(p, name, value, usage)
 }
 
 // IntVar defines an int flag with specified name, default value, and usage string.
@@ -458,7 +1031,6 @@ func IntConfigVar(p *int, name string, value int, usage string) {
     isFlag := false
     isConfig := true
     configuration.Var(newIntValue(value, p), name, usage, isFlag, isConfig)
-    flag.IntVar(p, name, value, usage)
 }
 
 // IntVar defines an int flag with specified name, default value, and usage string.
@@ -467,7 +1039,6 @@ func IntFlagVar(p *int, name string, value int, usage string) {
     isFlag := true
     isConfig := false
     configuration.Var(newIntValue(value, p), name, usage, isFlag, isConfig)
-    flag.IntVar(p, name, value, usage)
 }
 
 // Int defines an int flag with specified name, default value, and usage string.
@@ -518,7 +1089,6 @@ func (c *ConfigoSet) Int64Var(p *int64, name string, value int64, usage string)
     isFlag := true
     isConfig := true
     c.Var(newInt64Value(value, p), name, usage, isFlag, isConfig)
-    flag.Int64Var(p, name, value, usage)
 }
 
 // Int64Var defines an int64 flag with specified name, default value, and usage string.
@@ -527,7 +1097,6 @@ func (c *ConfigoSet) Int64FlagVar(p *int64, name string, value int64, usage stri
     isFlag := true
     isConfig := false
     c.Var(newInt64Value(value, p), name, usage, isFlag, isConfig)
-    flag.Int64Var(p, name, value, usage)
 }
 
 // Int64Var defines an int64 flag with specified name, default value, and usage string.
@@ -536,16 +1105,14 @@ func (c *ConfigoSet) Int64ConfigVar(p *int64, name string, value int64, usage st
     isFlag := false
     isConfig := true
     c.Var(newInt64Value(value, p), name, usage, isFlag, isConfig)
-    flag.Int64Var(p, name, value, usage)
 }
 
 // Int64Var defines an int64 flag with specified name, default value, and usage string.
 // The argument p points to an int64 variable in which to store the value of the flag.
 func Int64Var(p *int64, name string, value int64, usage string) {
     isFlag := true
-    isConfig := false
+    isConfig := true
     configuration.Var(newInt64Value(value, p), name, usage, isFlag, isConfig)
-    flag.Int64Var(p, name, value, usage)
 }
 
 // Int64Var defines an int64 flag with specified name, default value, and usage string.
@@ -562,7 +1129,6 @@ func Int64FlagVar(p *int64, name string, value int64, usage string) {
     isFlag := true
     isConfig := false
     configuration.Var(newInt64Value(value, p), name, usage, isFlag, isConfig)
-    flag.Int64Var(p, name, value, usage)
 }
 
 // Int64 defines an int64 flag with specified name, default value, and usage string.
@@ -613,7 +1179,6 @@ func (c *ConfigoSet) UintVar(p *uint, name string, value uint, usage string) {
     isFlag := true
     isConfig := true
     c.Var(newUintValue(value, p), name, usage, isFlag, isConfig)
-    flag.UintVar(p, name, value, usage)
 }
 
 // UintVar defines a uint flag with specified name, default value, and usage string.
@@ -622,16 +1187,14 @@ func (c *ConfigoSet) UintFlagVar(p *uint, name string, value uint, usage string)
     isFlag := true
     isConfig := false
     c.Var(newUintValue(value, p), name, usage, isFlag, isConfig)
-    flag.UintVar(p, name, value, usage)
 }
 
 // UintVar defines a uint flag with specified name, default value, and usage string.
 // The argument p points to a uint variable in which to store the value of the flag.
 func (c *ConfigoSet) UintConfigVar(p *uint, name string, value uint, usage string) {
-    isFlag := true
-    isConfig := false
+    isFlag := false
+    isConfig := true
     c.Var(newUintValue(value, p), name, usage, isFlag, isConfig)
-    flag.UintVar(p, name, value, usage)
 }
 
 // UintVar defines a uint flag with specified name, default value, and usage string.
@@ -640,7 +1203,6 @@ func UintVar(p *uint, name string, value uint, usage string) {
     isFlag := true
     isConfig := true
     configuration.Var(newUintValue(value, p), name, usage, isFlag, isConfig)
-    flag.UintVar(p, name, value, usage)
 }
 
 // UintVar defines a uint flag with specified name, default value, and usage string.
@@ -649,7 +1211,6 @@ func UintFlagVar(p *uint, name string, value uint, usage string) {
     isFlag := true
     isConfig := false
     configuration.Var(newUintValue(value, p), name, usage, isFlag, isConfig)
-    flag.UintVar(p, name, value, usage)
 }
 
 // UintVar defines a uint flag with specified name, default value, and usage string.
@@ -658,7 +1219,6 @@ func UintConfigVar(p *uint, name string, value uint, usage string) {
     isFlag := false
     isConfig := true
     configuration.Var(newUintValue(value, p), name, usage, isFlag, isConfig)
-    flag.UintVar(p, name, value, usage)
 }
 
 // Uint defines a uint flag with specified name, default value, and usage string.
@@ -681,7 +1241,7 @@ func (c *ConfigoSet) UintFlag(name string, value uint, usage string) *uint {
 // The return value is the address of a uint  variable that stores the value of the flag.
 func (c *ConfigoSet) UintConfig(name string, value uint, usage string) *uint {
     p := new(uint)
-    c.UintVar(p, name, value, usage)
+    c.UintConfigVar(p, name, value, usage)
     return p
 }
 
@@ -709,7 +1269,6 @@ func (c *ConfigoSet) Uint64Var(p *uint64, name string, value uint64, usage strin
     isFlag := true
     isConfig := true
     c.Var(newUint64Value(value, p), name, usage, isFlag, isConfig)
-    flag.Uint64Var(p, name, value, usage)
 }
 
 // Uint64Var defines a uint64 flag with specified name, default value, and usage string.
@@ -718,7 +1277,6 @@ func (c *ConfigoSet) Uint64FlagVar(p *uint64, name string, value uint64, usage s
     isFlag := true
     isConfig := false
     c.Var(newUint64Value(value, p), name, usage, isFlag, isConfig)
-    flag.Uint64Var(p, name, value, usage)
 }
 
 // Uint64Var defines a uint64 flag with specified name, default value, and usage string.
@@ -735,7 +1293,6 @@ func Uint64Var(p *uint64, name string, value uint64, usage string) {
     isFlag := true
     isConfig := true
     configuration.Var(newUint64Value(value, p), name, usage, isFlag, isConfig)
-    flag.Uint64Var(p, name, value, usage)
 }
 
 // Uint64Var defines a uint64 flag with specified name, default value, and usage string.
@@ -744,16 +1301,14 @@ func Uint64FlagVar(p *uint64, name string, value uint64, usage string) {
     isFlag := true
     isConfig := false
     configuration.Var(newUint64Value(value, p), name, usage, isFlag, isConfig)
-    flag.Uint64Var(p, name, value, usage)
 }
 
 // Uint64Var defines a uint64 flag with specified name, default value, and usage string.
 // The argument p points to a uint64 variable in which to store the value of the flag.
 func Uint64ConfigVar(p *uint64, name string, value uint64, usage string) {
-    isFlag := true
+    isFlag := false
     isConfig := true
     configuration.Var(newUint64Value(value, p), name, usage, isFlag, isConfig)
-    flag.Uint64Var(p, name, value, usage)
 }
 
 // Uint64 defines a uint64 flag with specified name, default value, and usage string.
@@ -804,7 +1359,6 @@ func (c *ConfigoSet) StringVar(p *string, name string, value string, usage strin
     isFlag := true
     isConfig := true
     c.Var(newStringValue(value, p), name, usage, isFlag, isConfig)
-    flag.StringVar(p, name, value, usage)
 }
 
 // StringVar defines a string flag with specified name, default value, and usage string.
@@ -813,7 +1367,6 @@ func (c *ConfigoSet) StringFlagVar(p *string, name string, value string, usage s
     isFlag := true
     isConfig := false
     c.Var(newStringValue(value, p), name, usage, isFlag, isConfig)
-    flag.StringVar(p, name, value, usage)
 }
 
 // StringVar defines a string flag with specified name, default value, and usage string.
@@ -830,7 +1383,6 @@ func StringVar(p *string, name string, value string, usage string) {
     isFlag := true
     isConfig := true
     configuration.Var(newStringValue(value, p), name, usage, isFlag, isConfig)
-    flag.StringVar(p, name, value, usage)
 }
 
 // StringVar defines a string flag with specified name, default value, and usage string.
@@ -839,7 +1391,6 @@ func StringFlagVar(p *string, name string, value string, usage string) {
     isFlag := true
     isConfig := false
     configuration.Var(newStringValue(value, p), name, usage, isFlag, isConfig)
-    flag.StringVar(p, name, value, usage)
 }
 
 // StringVar defines a string flag with specified name, default value, and usage string.
@@ -870,7 +1421,7 @@ func (c *ConfigoSet) StringFlag(name string, value string, usage string) *string
 // The return value is the address of a string variable that stores the value of the flag.
 func (c *ConfigoSet) StringConfig(name string, value string, usage string) *string {
     p := new(string)
-    c.StringFlagVar(p, name, value, usage)
+    c.StringConfigVar(p, name, value, usage)
     return p
 }
 
@@ -898,7 +1449,6 @@ func (c *ConfigoSet) Float64Var(p *float64, name string, value float64, usage st
     isFlag := true
     isConfig := true
     c.Var(newFloat64Value(value, p), name, usage, isFlag, isConfig)
-    flag.Float64Var(p, name, value, usage)
 }
 
 // Float64Var defines a float64 flag with specified name, default value, and usage string.
@@ -907,7 +1457,6 @@ func (c *ConfigoSet) Float64FlagVar(p *float64, name string, value float64, usag
     isFlag := true
     isConfig := false
     c.Var(newFloat64Value(value, p), name, usage, isFlag, isConfig)
-    flag.Float64Var(p, name, value, usage)
 }
 
 // Float64Var defines a float64 flag with specified name, default value, and usage string.
@@ -924,7 +1473,6 @@ func Float64Var(p *float64, name string, value float64, usage string) {
     isFlag := true
     isConfig := true
     configuration.Var(newFloat64Value(value, p), name, usage, isFlag, isConfig)
-    flag.Float64Var(p, name, value, usage)
 }
 
 // Float64Var defines a float64 flag with specified name, default value, and usage string.
@@ -933,7 +1481,6 @@ func Float64FlagVar(p *float64, name string, value float64, usage string) {
     isFlag := true
     isConfig := false
     configuration.Var(newFloat64Value(value, p), name, usage, isFlag, isConfig)
-    flag.Float64Var(p, name, value, usage)
 }
 
 // Float64Var defines a float64 flag with specified name, default value, and usage string.
@@ -992,7 +1539,6 @@ func (c *ConfigoSet) DurationVar(p *time.Duration, name string, value time.Durat
     isFlag := true
     isConfig := true
     c.Var(newDurationValue(value, p), name, usage, isFlag, isConfig)
-    flag.DurationVar(p, name, value, usage)
 }
 
 // DurationVar defines a time.Duration flag with specified name, default value, and usage string.
@@ -1001,7 +1547,6 @@ func (c *ConfigoSet) DurationFlagVar(p *time.Duration, name string, value time.D
     isFlag := true
     isConfig := false
     c.Var(newDurationValue(value, p), name, usage, isFlag, isConfig)
-    flag.DurationVar(p, name, value, usage)
 }
 
 // DurationVar defines a time.Duration flag with specified name, default value, and usage string.
@@ -1018,7 +1563,6 @@ func DurationVar(p *time.Duration, name string, value time.Duration, usage strin
     isFlag := true
     isConfig := true
     configuration.Var(newDurationValue(value, p), name, usage, isFlag, isConfig)
-    flag.DurationVar(p, name, value, usage)
 }
 
 // DurationVar defines a time.Duration flag with specified name, default value, and usage string.
@@ -1027,7 +1571,6 @@ func DurationFlagVar(p *time.Duration, name string, value time.Duration, usage s
     isFlag := true
     isConfig := false
     configuration.Var(newDurationValue(value, p), name, usage, isFlag, isConfig)
-    flag.DurationVar(p, name, value, usage)
 }
 
 // DurationVar defines a time.Duration flag with specified name, default value, and usage string.
@@ -1088,7 +1631,14 @@ func DurationConfig(name string, value time.Duration, usage string) *time.Durati
 // decompose the comma-separated string into the slice.
 func (c *ConfigoSet) Var(value flag.Value, name string, usage string, isFlag, isConfig bool) {
     // Remember the default value as a string; it won't change.
-    config := &Configo{name, usage, value, value.String(), isFlag, isConfig}
+    config := &Configo{
+        Name:         name,
+        Usage:        usage,
+        Value:        value,
+        DefaultValue: value.String(),
+        IsFlag:       isFlag,
+        IsConfig:     isConfig,
+    }
     _, alreadythere := c.formal[name]
     if alreadythere {
         msg := fmt.Sprintf("%s flag redefined: %s", c.name, name)
@@ -1099,6 +1649,10 @@ func (c *ConfigoSet) Var(value flag.Value, name string, usage string, isFlag, is
         c.formal = make(map[string]*Configo)
     }
     c.formal[name] = config
+
+    if isFlag {
+        c.flags.Var(value, name, usage)
+    }
 }
 
 // Var defines a flag with the specified name and usage string. The type and
@@ -1110,34 +1664,61 @@ func (c *ConfigoSet) Var(value flag.Value, name string, usage string, isFlag, is
 // TODO This function does not appear to be used.
 func Var(value flag.Value, name string, usage string, isFlag, isConfig bool) {
     configuration.Var(value, name, usage, isFlag, isConfig)
-
-    if isFlag {
-        flag.Var(value, name, usage)
-    }
 }
 
 // NArg is the number of arguments remaining after flags have been processed.
 func (c *ConfigoSet) NArg() int {
-    return flag.NArg()
+    return len(c.args)
 }
 
 // NFlag returns the number of command-line flags that have been set.
 func (c *ConfigoSet) NFlag() int {
-    return flag.NFlag()
+    return c.nflag
 }
 
 // Parse parses the command-line flags from os.Args[1:] and sets the values in
 // this ConfigoSet.  Then the configuration file is parsed and any item that
 // was not already set by the command line is set.  Must be called after all
 // configuration options are defined and before conifguration options are
-// accessed by the program.
+// accessed by the program.  If the path to the configuration file is "-" the
+// configuration is read from standard input.
 func (c *ConfigoSet) Parse() (err error) {
-    // Start by parsing the command line with the flag package and then set the
-    // parsed values into the ConfigoSet.
-    flag.Parse()
-    flag.Visit(func(f *flag.Flag) {
-        c.Set(f.Name, f.Value.String())
-    })
+    if err = c.parseFlags(); err != nil {
+        return
+    }
+    return c.parseFiles()
+}
+
+// parseFiles reads the embedded defaults and the configuration file, once the
+// command line has been parsed.
+func (c *ConfigoSet) parseFiles() error {
+    c.selectProfile()
+    return c.readFiles()
+}
+
+// readFiles reads the embedded defaults and the configuration file.
+func (c *ConfigoSet) readFiles() (err error) {
+    if err = c.parseEmbedded(); err != nil {
+        return
+    }
+
+    // A configuration file named on the command line replaces the default
+    // path, and must exist.
+    explicit := false
+    if c.configFlags {
+        if c.noConfig {
+            c.parsed = true
+            return
+        }
+        if config := c.Lookup(configFlagName); config.source == sourceFlag {
+            c.path = c.configPath
+            explicit = true
+        }
+    }
+
+    if c.path == "-" {
+        return c.parseConfig(os.Stdin, "-")
+    }
 
     // Now parse the configuration file, but first create the config file if it
     // does not exist.  If that's the case we're all done and we can return.
@@ -1145,47 +1726,38 @@ func (c *ConfigoSet) Parse() (err error) {
         if !os.IsNotExist(err) {
             return
         }
+        if explicit {
+            return fmt.Errorf("configuration file %s does not exist", c.path)
+        }
 
         c.parsed = true
-        err = c.WriteDefaultConfig(c.path)
+        err = nil
+        if c.shouldCreate() {
+            fmt.Fprintln(c.out(), "Writing a default configuration file to", c.path)
+            err = c.WriteDefaultConfig(c.path)
+        }
         return
     }
+    if c.writeDefault {
+        fmt.Fprintln(c.out(), "Not writing a default configuration file,", c.path, "already exists")
+    }
 
     // Parse the config file, but only set the options that didn't appear on
     // the command line.
     if !c.parsed {
-        var content []byte
-        content, err = ioutil.ReadFile(c.path)
-        if err != nil {
+        var file *os.File
+        if file, err = os.Open(c.path); err != nil {
             return
         }
+        defer file.Close()
 
-        for i, line := range strings.Split(string(content), "\n") {
-            line = strings.TrimSpace(line)
-
-            if len(line) > 0 && !strings.HasPrefix(line, "#") {
-                var name, value string
-                fields := strings.SplitN(line, c.delimiter, 2)
-                if len(fields) != 2 {
-                    errors.New(fmt.Sprintf("Invalid key%svalue pair in conifiguration file %s on line %d.\n", c.delimiter, c.path, i))
-                }
-                name = strings.TrimSpace(fields[0])
-                value = strings.TrimSpace(fields[1])
-
-                // Check if the item was already set from the command line.
-                if _, exists := c.actual[name]; !exists {
-                    // Is this even a valid config item?
-                    config := c.Lookup(name)
-                    if config == nil {
-                        panic(errors.New("unknown configuration item"))
-                    }
-
-                    c.Set(name, value)
-                }
-            }
+        if err = c.checkPermissions(file, c.path); err != nil {
+            return
         }
 
-        c.parsed = true
+        if err = c.parseConfig(file, c.path); err == nil {
+            c.warnSecretFile(c.path)
+        }
     }
 
     return
@@ -1195,12 +1767,312 @@ func Parse() error {
     return configuration.Parse()
 }
 
+// ParseReader parses the command-line flags like Parse, but reads the
+// configuration from r instead of the configuration file.  The name is used
+// in error messages and its extension chooses the format, as it would for a
+// file.  To parse a configuration held in a byte slice use bytes.NewReader.
+func (c *ConfigoSet) ParseReader(r io.Reader, name string) error {
+    if err := c.parseFlags(); err != nil {
+        return err
+    }
+    c.selectProfile()
+    if err := c.parseEmbedded(); err != nil {
+        return err
+    }
+    return c.parseConfig(r, name)
+}
+
+// ParseReader parses the command-line flags like Parse, but reads the
+// configuration from r instead of the configuration file.
+func ParseReader(r io.Reader, name string) error {
+    return configuration.ParseReader(r, name)
+}
+
+// ParseFS parses the command-line flags like Parse, but reads the
+// configuration from the named file in fsys, such as an embed.FS or a
+// testing/fstest.MapFS, instead of the configuration file.
+func (c *ConfigoSet) ParseFS(fsys fs.FS, path string) error {
+    file, err := fsys.Open(path)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+    return c.ParseReader(file, path)
+}
+
+// ParseFS parses the command-line flags like Parse, but reads the
+// configuration from the named file in fsys instead of the configuration file.
+func ParseFS(fsys fs.FS, path string) error {
+    return configuration.ParseFS(fsys, path)
+}
+
+// parseFlags parses the command line.  With GoSyntax the flag package parses
+// it and then the items it set are marked in the ConfigoSet.  The flag package
+// has already stored the values, so they are not set a second time.
+//
+// The flags of a subcommand's parent sets are accepted too.  Then the declared
+// positional arguments are set from the remaining arguments.
+func (c *ConfigoSet) parseFlags() error {
+    arguments := c.arguments
+    if arguments == nil {
+        arguments = os.Args[1:]
+    }
+
+    if c.syntax == GNUSyntax {
+        if err := c.parseGNU(arguments); err != nil {
+            return c.failf(err)
+        }
+        if c.completion != "" {
+            return c.writeCompletion()
+        }
+        return c.parseArgs()
+    }
+
+    // The flag package reports errors in the set's own flags itself.
+    flags := c.flags
+    if c.parent != nil {
+        flags = c.inheritedFlags()
+        if err := flags.Parse(arguments); err != nil {
+            return c.failf(err)
+        }
+    } else if err := flags.Parse(arguments); err != nil {
+        return err
+    }
+    c.args = flags.Args()
+    c.nflag = flags.NFlag()
+    flags.Visit(func(f *flag.Flag) {
+        if set, config := c.lookupInherited(f.Name); config != nil {
+            set.warnDeprecated(f.Name, "command line")
+            set.mark(config, sourceFlag)
+        }
+    })
+    if c.completion != "" {
+        return c.writeCompletion()
+    }
+    return c.parseArgs()
+}
+
+// inheritedFlags returns a flag set holding the flags of c and of its parent
+// sets, which a subcommand accepts on its command line.  Errors are reported
+// by failf rather than the flag package.
+func (c *ConfigoSet) inheritedFlags() *flag.FlagSet {
+    flags := flag.NewFlagSet(c.name, flag.ContinueOnError)
+    flags.SetOutput(ioutil.Discard)
+    flags.Usage = func() {}
+    for set := c; set != nil; set = set.parent {
+        set.flags.VisitAll(func(f *flag.Flag) {
+            if flags.Lookup(f.Name) == nil {
+                flags.Var(f.Value, f.Name, f.Usage)
+            }
+        })
+    }
+    return flags
+}
+
+// lookupInherited returns the item set by the named command line flag and
+// the set it belongs to, looking in c and then its parent sets.
+func (c *ConfigoSet) lookupInherited(name string) (*ConfigoSet, *Configo) {
+    for set := c; set != nil; set = set.parent {
+        if config := set.lookupFlag(name); config != nil {
+            return set, config
+        }
+    }
+    return nil, nil
+}
+
+// parseEmbedded reads the embedded default configuration, if there is one.
+func (c *ConfigoSet) parseEmbedded() error {
+    if c.embedded == nil || c.parsed {
+        return nil
+    }
+
+    file, err := c.embedded.Open(c.embeddedName)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+    return c.readConfig(file, c.embeddedName, sourceEmbedded+c.embeddedName)
+}
+
+// parseConfig reads the configuration file from r, which is named name, and
+// sets every item that was not already set by the command line.
+func (c *ConfigoSet) parseConfig(r io.Reader, name string) error {
+    if c.parsed {
+        return nil
+    }
+    if err := c.readConfig(r, name, sourceFile+name); err != nil {
+        return err
+    }
+    c.parsed = true
+    return c.checkProfile(name)
+}
+
+// readConfig reads the configuration from r, which is named name, and sets
+// every item that was not already set by a source of higher precedence.
+func (c *ConfigoSet) readConfig(r io.Reader, name, source string) error {
+    format, err := c.formatFor(name)
+    if err != nil {
+        return err
+    }
+
+    entries, err := format.Decode(r)
+    if err != nil {
+        return fmt.Errorf("%s: %v", name, err)
+    }
+
+    // The values of the matching blocks and of the selected profile overlay
+    // the others.
+    entries, profiles := c.splitProfiles(entries)
+    entries, blocks := c.splitMatches(entries)
+    if c.profiles == nil {
+        c.profiles = make(map[string]bool)
+    }
+    for profile := range profiles {
+        c.profiles[profile] = true
+    }
+    if err = c.checkProfiles(profiles, name); err != nil {
+        return err
+    }
+    if err = c.setEntries(entries, name, source); err != nil {
+        return err
+    }
+
+    // Then the values of the matching blocks overlay them, and those of the
+    // selected profile overlay both.
+    for _, block := range blocks {
+        matched, err := matchCondition(block.condition)
+        if err != nil {
+            return fmt.Errorf("invalid condition %s in %s on line %d: %v", block.condition, name, block.line, err)
+        }
+        if !matched {
+            // Check the items anyway, so that a mistake does not wait for
+            // a machine where the block applies.
+            for _, e := range block.entries {
+                if !c.isEntryName(e.Name) {
+                    return fmt.Errorf("unknown configuration item %s in match section %s in %s on line %d", e.Name, block.condition, name, e.Line)
+                }
+            }
+            continue
+        }
+        section := matchSection + " " + block.condition
+        if err = c.setEntries(block.entries, name, sectionSource(source, section)); err != nil {
+            return err
+        }
+    }
+
+    if c.profile == "" {
+        return nil
+    }
+    return c.setEntries(profiles[c.profile], name, sectionSource(source, profileSection+" "+c.profile))
+}
+
+// setEntries sets the items named by entries read from the configuration named
+// name, unless they were set by a source of higher precedence.
+func (c *ConfigoSet) setEntries(entries []Entry, name, source string) (err error) {
+    for _, e := range entries {
+        // Is this even a valid config item?
+        set, config, local := c.lookupEntry(e.Name)
+        secretSource := false
+        if config == nil {
+            // A secret may be read from a file or a command.
+            set, config, local = c.lookupSecretSource(e.Name)
+            secretSource = config != nil
+        }
+        if config == nil {
+            return fmt.Errorf("unknown configuration item %s in %s on line %d", e.Name, name, e.Line)
+        }
+        set.warnDeprecated(local, fmt.Sprintf("%s:%d", name, e.Line))
+
+        // Skip items already set by a source of higher precedence, such as
+        // the command line.
+        if precedence(config.source) > precedence(source) {
+            continue
+        }
+
+        // Only now that the secret is wanted, read its file or run its
+        // command.
+        value := e.Value
+        if secretSource {
+            if value, err = readSecretSource(e); err != nil {
+                return fmt.Errorf("%v for %s in %s on line %d", err, e.Name, name, e.Line)
+            }
+        }
+        if strings.HasPrefix(value, EncryptedPrefix) {
+            if value, err = c.decrypt(set.qualifiedName(config.Name), config, value); err != nil {
+                return fmt.Errorf("invalid encrypted value for %s in %s on line %d: %v", e.Name, name, e.Line, err)
+            }
+        }
+        if err = set.set(config, value, source); err != nil {
+            return fmt.Errorf("invalid value for %s in %s on line %d: %v", e.Name, name, e.Line, err)
+        }
+    }
+    return nil
+}
+
+// lookupEntry returns the item named by a configuration file entry, the set it
+// belongs to and its name in that set.  An entry in a subcommand's section,
+// such as "serve.port", belongs to the subcommand's set.
+func (c *ConfigoSet) lookupEntry(name string) (*ConfigoSet, *Configo, string) {
+    if config := c.Lookup(name); config != nil {
+        return c, config, name
+    }
+    if section, rest, ok := strings.Cut(name, "."); ok {
+        if sub, ok := c.commands[section]; ok {
+            return sub.lookupEntry(rest)
+        }
+    }
+    return nil, nil, name
+}
+
+// fileConfigs returns the items which belong in the configuration file,
+// leaving out deprecated ones.  The items of subcommands follow, with their
+// names qualified by their section, such as "serve.port".
+func (c *ConfigoSet) fileConfigs(includeFlagOnly bool) []*Configo {
+    var configs []*Configo
+    c.VisitAll(func(config *Configo) {
+        if (config.IsConfig || includeFlagOnly) && !config.Hidden && !c.isDeprecated(config.Name) {
+            configs = append(configs, config)
+        }
+    })
+
+    names := make([]string, 0, len(c.commands))
+    for name := range c.commands {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        for _, config := range c.commands[name].fileConfigs(includeFlagOnly) {
+            qualified := *config
+            qualified.Name = name + "." + config.Name
+            configs = append(configs, &qualified)
+        }
+    }
+    return configs
+}
+
+// SetEmbeddedDefaults sets a default configuration, usually embedded in the
+// program with the embed package, which is read from the named file in fsys
+// with the same rules as the configuration file.  Its values override the
+// registered defaults, but are overridden by the configuration file and the
+// command line.
+func (c *ConfigoSet) SetEmbeddedDefaults(fsys fs.FS, name string) {
+    c.embedded = fsys
+    c.embeddedName = name
+}
+
+// SetEmbeddedDefaults sets a default configuration, usually embedded in the
+// program with the embed package, which is read from the named file in fsys
+// with the same rules as the configuration file.
+func SetEmbeddedDefaults(fsys fs.FS, name string) {
+    configuration.SetEmbeddedDefaults(fsys, name)
+}
+
 /*
 Parsed returns true if the configuration file and command-line flags have been
 parsed.
 */
 func (c *ConfigoSet) Parsed() bool {
-    return c.parsed && flag.Parsed()
+    return c.parsed
 }
 
 /*
@@ -1209,12 +2081,15 @@ command-line flags.
 */
 func (c *ConfigoSet) PrintDefaults() {
     c.VisitAll(func(config *Configo) {
-        format := "  -%s=%s: %s\n"
+        if config.Hidden || c.isDeprecated(config.Name) {
+            return
+        }
+        format := "  %s=%s: %s\n"
         if _, ok := config.Value.(*stringValue); ok {
             // put quotes on the value
-            format = "  -%s=%q: %s\n"
+            format = "  %s=%q: %s\n"
         }
-        fmt.Fprintf(c.out(), format, config.Name, config.DefaultValue, config.Usage)
+        fmt.Fprintf(c.out(), format, c.flagNames(config), config.DefaultValue, config.Usage)
     })
 }
 
@@ -1227,23 +2102,79 @@ func (c *ConfigoSet) out() io.Writer {
     return c.output
 }
 
+// SetOutput sets the destination for usage and error messages and warnings.
+// If output is nil, os.Stderr is used.
+func (c *ConfigoSet) SetOutput(output io.Writer) {
+    c.output = output
+    c.flags.SetOutput(output)
+}
+
 /*
-Set sets the value of the named configuration item.
+Set sets the value of the named configuration item.  Values set by the program
+take precedence over the configuration file, just like the command line.
 */
 func (c *ConfigoSet) Set(name, value string) error {
-    config, ok := c.formal[name]
-    if !ok {
+    config := c.Lookup(name)
+    if config == nil {
         return fmt.Errorf("no such configuration item %v", name)
     }
+    return c.set(config, value, sourceSet)
+}
+
+// set sets the value of config and records where the value came from.
+func (c *ConfigoSet) set(config *Configo, value, source string) error {
     err := config.Value.Set(value)
     if err != nil {
         return err
     }
+    c.mark(config, source)
+    return nil
+}
+
+// mark records that config has been set from source.
+func (c *ConfigoSet) mark(config *Configo, source string) {
     if c.actual == nil {
         c.actual = make(map[string]*Configo)
     }
-    c.actual[name] = config
-    return nil
+    c.actual[config.Name] = config
+    config.source = source
+}
+
+// The sources of a configuration item's value.  Embedded and file sources
+// are followed by the name of the file.
+const (
+    sourceDefault  = "default"
+    sourceEmbedded = "embedded:"
+    sourceFile     = "file:"
+    sourceFlag     = "flag"
+    sourceSet      = "set"
+)
+
+// precedence returns the precedence of a source.  A value is never replaced
+// by one from a source of lower precedence.
+func precedence(source string) int {
+    switch {
+    case source == "":
+        return 0
+    case strings.HasPrefix(source, sourceEmbedded):
+        return 1
+    case strings.HasPrefix(source, sourceFile):
+        return 2
+    }
+    return 3
+}
+
+// Source returns where the current value of the item came from: "default"
+// for the registered default, "embedded:<name>" for the embedded defaults,
+// "file:<path>" for a configuration file, "flag" for the command line or "set"
+// for a value set by the program.  A value from a profile of the embedded
+// defaults or configuration file is followed by the profile, as in
+// "file:<path> [profile staging]".
+func (config *Configo) Source() string {
+    if config.source == "" {
+        return sourceDefault
+    }
+    return config.source
 }
 
 /*
@@ -1295,18 +2226,26 @@ func sortConfigs(configs map[string]*Configo) []*Configo {
     return result
 }
 
+// SetDelimiter sets the delimiter of the key/value pairs in the built-in
+// "keyvalue" format, which is "=" by default.
 func SetDelimiter(d string) {
     configuration.delimiter = d
 }
 
 // Lookup returns the Configo structure of the named configo, returning nil if
-// none exists.
+// none exists.  An alias returns the item it refers to.
 func (c *ConfigoSet) Lookup(name string) *Configo {
-    return c.formal[name]
+    if config, ok := c.formal[name]; ok {
+        return config
+    }
+    if new, ok := c.aliases[name]; ok {
+        return c.formal[new]
+    }
+    return nil
 }
 
 // Lookup returns the Configo structure of the named configuration item,
 // returning nil if none exists.
 func Lookup(name string) *Configo {
-    return configuration.formal[name]
+    return configuration.Lookup(name)
 }
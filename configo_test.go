@@ -0,0 +1,481 @@
+package configo
+
+import (
+    "bytes"
+    "errors"
+    "io"
+    "io/fs"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "runtime"
+    "strings"
+    "testing"
+    "testing/fstest"
+    "time"
+)
+
+func TestRegistrationKinds(t *testing.T) {
+    c := NewConfigoSet("test", 0, "")
+    const (
+        both = iota
+        flagOnly
+        configOnly
+    )
+    tests := []struct {
+        name     string
+        register func(name string)
+        kind     int
+    }{
+        {"bool", func(n string) { c.Bool(n, false, "") }, both},
+        {"boolflag", func(n string) { c.BoolFlag(n, false, "") }, flagOnly},
+        {"boolconfig", func(n string) { c.BoolConfig(n, false, "") }, configOnly},
+        {"int", func(n string) { c.Int(n, 0, "") }, both},
+        {"intflag", func(n string) { c.IntFlag(n, 0, "") }, flagOnly},
+        {"intconfig", func(n string) { c.IntConfig(n, 0, "") }, configOnly},
+        {"int64", func(n string) { c.Int64(n, 0, "") }, both},
+        {"int64flag", func(n string) { c.Int64Flag(n, 0, "") }, flagOnly},
+        {"int64config", func(n string) { c.Int64Config(n, 0, "") }, configOnly},
+        {"uint", func(n string) { c.Uint(n, 0, "") }, both},
+        {"uintflag", func(n string) { c.UintFlag(n, 0, "") }, flagOnly},
+        {"uintconfig", func(n string) { c.UintConfig(n, 0, "") }, configOnly},
+        {"uint64", func(n string) { c.Uint64(n, 0, "") }, both},
+        {"uint64flag", func(n string) { c.Uint64Flag(n, 0, "") }, flagOnly},
+        {"uint64config", func(n string) { c.Uint64Config(n, 0, "") }, configOnly},
+        {"string", func(n string) { c.String(n, "", "") }, both},
+        {"stringflag", func(n string) { c.StringFlag(n, "", "") }, flagOnly},
+        {"stringconfig", func(n string) { c.StringConfig(n, "", "") }, configOnly},
+        {"float64", func(n string) { c.Float64(n, 0, "") }, both},
+        {"float64flag", func(n string) { c.Float64Flag(n, 0, "") }, flagOnly},
+        {"float64config", func(n string) { c.Float64Config(n, 0, "") }, configOnly},
+        {"duration", func(n string) { c.Duration(n, time.Second, "") }, both},
+        {"durationflag", func(n string) { c.DurationFlag(n, time.Second, "") }, flagOnly},
+        {"durationconfig", func(n string) { c.DurationConfig(n, time.Second, "") }, configOnly},
+    }
+    for _, test := range tests {
+        test.register(test.name)
+        config := c.Lookup(test.name)
+        wantFlag, wantConfig := test.kind != configOnly, test.kind != flagOnly
+        if config.IsFlag != wantFlag || config.IsConfig != wantConfig {
+            t.Errorf("%s: IsFlag, IsConfig = %v, %v, want %v, %v", test.name, config.IsFlag, config.IsConfig, wantFlag, wantConfig)
+        }
+        if registered := c.flags.Lookup(test.name) != nil; registered != wantFlag {
+            t.Errorf("%s: registered as a command line flag = %v, want %v", test.name, registered, wantFlag)
+        }
+    }
+}
+
+// The package-level functions register items in the default set.
+func TestPackageRegistrationKinds(t *testing.T) {
+    defer func(c *ConfigoSet) { configuration = c }(configuration)
+    configuration = NewConfigoSet("test", 0, "")
+    const (
+        both = iota
+        flagOnly
+        configOnly
+    )
+    tests := []struct {
+        name     string
+        register func(name string)
+        kind     int
+    }{
+        {"bool", func(n string) { Bool(n, false, "") }, both},
+        {"boolvar", func(n string) { BoolVar(new(bool), n, false, "") }, both},
+        {"boolflag", func(n string) { BoolFlag(n, false, "") }, flagOnly},
+        {"boolflagvar", func(n string) { BoolFlagVar(new(bool), n, false, "") }, flagOnly},
+        {"boolconfig", func(n string) { BoolConfig(n, false, "") }, configOnly},
+        {"boolconfigvar", func(n string) { BoolConfigVar(new(bool), n, false, "") }, configOnly},
+        {"int", func(n string) { Int(n, 0, "") }, both},
+        {"intvar", func(n string) { IntVar(new(int), n, 0, "") }, both},
+        {"intflag", func(n string) { IntFlag(n, 0, "") }, flagOnly},
+        {"intflagvar", func(n string) { IntFlagVar(new(int), n, 0, "") }, flagOnly},
+        {"intconfig", func(n string) { IntConfig(n, 0, "") }, configOnly},
+        {"intconfigvar", func(n string) { IntConfigVar(new(int), n, 0, "") }, configOnly},
+        {"int64", func(n string) { Int64(n, 0, "") }, both},
+        {"int64var", func(n string) { Int64Var(new(int64), n, 0, "") }, both},
+        {"int64flag", func(n string) { Int64Flag(n, 0, "") }, flagOnly},
+        {"int64flagvar", func(n string) { Int64FlagVar(new(int64), n, 0, "") }, flagOnly},
+        {"int64config", func(n string) { Int64Config(n, 0, "") }, configOnly},
+        {"int64configvar", func(n string) { Int64ConfigVar(new(int64), n, 0, "") }, configOnly},
+        {"uint", func(n string) { Uint(n, 0, "") }, both},
+        {"uintvar", func(n string) { UintVar(new(uint), n, 0, "") }, both},
+        {"uintflag", func(n string) { UintFlag(n, 0, "") }, flagOnly},
+        {"uintflagvar", func(n string) { UintFlagVar(new(uint), n, 0, "") }, flagOnly},
+        {"uintconfig", func(n string) { UintConfig(n, 0, "") }, configOnly},
+        {"uintconfigvar", func(n string) { UintConfigVar(new(uint), n, 0, "") }, configOnly},
+        {"uint64", func(n string) { Uint64(n, 0, "") }, both},
+        {"uint64var", func(n string) { Uint64Var(new(uint64), n, 0, "") }, both},
+        {"uint64flag", func(n string) { Uint64Flag(n, 0, "") }, flagOnly},
+        {"uint64flagvar", func(n string) { Uint64FlagVar(new(uint64), n, 0, "") }, flagOnly},
+        {"uint64config", func(n string) { Uint64Config(n, 0, "") }, configOnly},
+        {"uint64configvar", func(n string) { Uint64ConfigVar(new(uint64), n, 0, "") }, configOnly},
+        {"string", func(n string) { String(n, "", "") }, both},
+        {"stringvar", func(n string) { StringVar(new(string), n, "", "") }, both},
+        {"stringflag", func(n string) { StringFlag(n, "", "") }, flagOnly},
+        {"stringflagvar", func(n string) { StringFlagVar(new(string), n, "", "") }, flagOnly},
+        {"stringconfig", func(n string) { StringConfig(n, "", "") }, configOnly},
+        {"stringconfigvar", func(n string) { StringConfigVar(new(string), n, "", "") }, configOnly},
+        {"float64", func(n string) { Float64(n, 0, "") }, both},
+        {"float64var", func(n string) { Float64Var(new(float64), n, 0, "") }, both},
+        {"float64flag", func(n string) { Float64Flag(n, 0, "") }, flagOnly},
+        {"float64flagvar", func(n string) { Float64FlagVar(new(float64), n, 0, "") }, flagOnly},
+        {"float64config", func(n string) { Float64Config(n, 0, "") }, configOnly},
+        {"float64configvar", func(n string) { Float64ConfigVar(new(float64), n, 0, "") }, configOnly},
+        {"duration", func(n string) { Duration(n, time.Second, "") }, both},
+        {"durationvar", func(n string) { DurationVar(new(time.Duration), n, time.Second, "") }, both},
+        {"durationflag", func(n string) { DurationFlag(n, time.Second, "") }, flagOnly},
+        {"durationflagvar", func(n string) { DurationFlagVar(new(time.Duration), n, time.Second, "") }, flagOnly},
+        {"durationconfig", func(n string) { DurationConfig(n, time.Second, "") }, configOnly},
+        {"durationconfigvar", func(n string) { DurationConfigVar(new(time.Duration), n, time.Second, "") }, configOnly},
+    }
+    for _, test := range tests {
+        test.register(test.name)
+        config := Lookup(test.name)
+        wantFlag, wantConfig := test.kind != configOnly, test.kind != flagOnly
+        if config.IsFlag != wantFlag || config.IsConfig != wantConfig {
+            t.Errorf("%s: IsFlag, IsConfig = %v, %v, want %v, %v", test.name, config.IsFlag, config.IsConfig, wantFlag, wantConfig)
+        }
+        if registered := configuration.flags.Lookup(test.name) != nil; registered != wantFlag {
+            t.Errorf("%s: registered as a command line flag = %v, want %v", test.name, registered, wantFlag)
+        }
+    }
+}
+
+func TestParseReader(t *testing.T) {
+    tests := []struct {
+        name    string
+        content string
+        args    []string
+        want    int
+        source  string
+    }{
+        {name: "rc", content: "port=81\n", args: []string{}, want: 81, source: "file:rc"},
+        {name: "rc.toml", content: "port = 81\n", args: []string{}, want: 81, source: "file:rc.toml"},
+        {name: "fixture.yaml", content: "port: 81\n", args: []string{}, want: 81, source: "file:fixture.yaml"},
+        {name: "rc", content: "port=81\n", args: []string{"-port=82"}, want: 82, source: "flag"},
+        {name: "rc", content: "", args: []string{}, want: 80, source: "default"},
+    }
+    for _, test := range tests {
+        c := NewConfigoSet("test", 0, "")
+        port := c.Int("port", 80, "the port")
+        c.SetArguments(test.args)
+        if err := c.ParseReader(bytes.NewReader([]byte(test.content)), test.name); err != nil {
+            t.Errorf("%s %q: ParseReader: %v", test.name, test.args, err)
+            continue
+        }
+        if *port != test.want || c.Lookup("port").Source() != test.source {
+            t.Errorf("%s %q: port %d from %q, want %d from %q", test.name, test.args,
+                *port, c.Lookup("port").Source(), test.want, test.source)
+        }
+    }
+
+    // Errors name the configuration.
+    c := NewConfigoSet("test", 0, "")
+    c.SetOutput(ioutil.Discard)
+    c.Int("port", 80, "the port")
+    c.SetArguments([]string{})
+    err := c.ParseReader(strings.NewReader("port=x\n"), "fixture")
+    if err == nil || !strings.Contains(err.Error(), "fixture") {
+        t.Errorf("ParseReader of an invalid value: %v, want an error naming fixture", err)
+    }
+}
+
+func TestParseFS(t *testing.T) {
+    fsys := fstest.MapFS{
+        "etc/rc":      {Data: []byte("port=81\n")},
+        "etc/rc.toml": {Data: []byte("port = 82\n")},
+        "etc/rc.yaml": {Data: []byte("port: 83\n")},
+    }
+    tests := []struct {
+        path string
+        want int
+    }{
+        {"etc/rc", 81},
+        {"etc/rc.toml", 82},
+        {"etc/rc.yaml", 83},
+    }
+    for _, test := range tests {
+        c := NewConfigoSet("test", 0, "")
+        port := c.Int("port", 80, "the port")
+        c.SetArguments([]string{})
+        if err := c.ParseFS(fsys, test.path); err != nil {
+            t.Errorf("%s: ParseFS: %v", test.path, err)
+            continue
+        }
+        if *port != test.want || c.Lookup("port").Source() != "file:"+test.path {
+            t.Errorf("%s: port %d from %q, want %d from the file", test.path, *port, c.Lookup("port").Source(), test.want)
+        }
+    }
+
+    c := NewConfigoSet("test", 0, "")
+    c.SetArguments([]string{})
+    if err := c.ParseFS(fsys, "etc/missing"); !errors.Is(err, fs.ErrNotExist) {
+        t.Errorf("ParseFS of a missing file: %v, want %v", err, fs.ErrNotExist)
+    }
+}
+
+func TestParseStdin(t *testing.T) {
+    stdin, err := ioutil.TempFile(t.TempDir(), "stdin")
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer stdin.Close()
+    if _, err := stdin.WriteString("port = 81\n"); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := stdin.Seek(0, io.SeekStart); err != nil {
+        t.Fatal(err)
+    }
+    defer func(saved *os.File) { os.Stdin = saved }(os.Stdin)
+    os.Stdin = stdin
+
+    // Standard input has no extension to choose the format by.
+    c := NewConfigoSet("test", 0, filepath.Join(t.TempDir(), "rc.toml"))
+    c.EnableConfigFlags()
+    c.SetFormat("toml")
+    port := c.Int("port", 80, "the port")
+    c.SetArguments([]string{"-config", "-"})
+    if err := c.Parse(); err != nil {
+        t.Fatalf("Parse: %v", err)
+    }
+    if *port != 81 || c.Lookup("port").Source() != "file:-" {
+        t.Errorf("port %d from %q, want 81 from standard input", *port, c.Lookup("port").Source())
+    }
+}
+
+func TestEmbeddedDefaults(t *testing.T) {
+    embedded := fstest.MapFS{
+        "defaults.toml": {Data: []byte("embedded = 1\nfile = 1\nflag = 1\n")},
+    }
+    path := filepath.Join(t.TempDir(), "rc")
+    if err := ioutil.WriteFile(path, []byte("file=2\nflag=2\n"), 0600); err != nil {
+        t.Fatal(err)
+    }
+
+    c := NewConfigoSet("test", 0, path)
+    c.SetEmbeddedDefaults(embedded, "defaults.toml")
+    values := make(map[string]*int)
+    for _, name := range []string{"default", "embedded", "file", "flag"} {
+        values[name] = c.Int(name, 0, "set by "+name)
+    }
+    c.SetArguments([]string{"-flag=3"})
+    if err := c.Parse(); err != nil {
+        t.Fatalf("Parse: %v", err)
+    }
+
+    tests := []struct {
+        name   string
+        want   int
+        source string
+    }{
+        {"default", 0, "default"},
+        {"embedded", 1, "embedded:defaults.toml"},
+        {"file", 2, "file:" + path},
+        {"flag", 3, "flag"},
+    }
+    for _, test := range tests {
+        if *values[test.name] != test.want || c.Lookup(test.name).Source() != test.source {
+            t.Errorf("%s: %d from %q, want %d from %q", test.name, *values[test.name],
+                c.Lookup(test.name).Source(), test.want, test.source)
+        }
+    }
+
+    // Without a configuration file the embedded defaults still apply.
+    c = NewConfigoSet("test", 0, filepath.Join(t.TempDir(), "rc"))
+    c.SetCreatePolicy(NeverCreate)
+    c.SetEmbeddedDefaults(embedded, "defaults.toml")
+    for _, name := range []string{"embedded", "flag"} {
+        c.Int(name, 0, "set by "+name)
+    }
+    file := c.Int("file", 0, "set by file")
+    c.SetArguments([]string{})
+    if err := c.Parse(); err != nil || *file != 1 || c.Lookup("file").Source() != "embedded:defaults.toml" {
+        t.Errorf("without a file: %d from %q: %v", *file, c.Lookup("file").Source(), err)
+    }
+
+    c = NewConfigoSet("test", 0, path)
+    c.SetEmbeddedDefaults(embedded, "missing.toml")
+    c.SetArguments([]string{})
+    if err := c.Parse(); !errors.Is(err, fs.ErrNotExist) {
+        t.Errorf("missing embedded defaults: %v, want %v", err, fs.ErrNotExist)
+    }
+}
+
+func TestConfigFlags(t *testing.T) {
+    dir := t.TempDir()
+    path := filepath.Join(dir, "rc")
+    other := filepath.Join(dir, "other")
+    missing := filepath.Join(dir, "missing")
+    for file, content := range map[string]string{path: "port=81\n", other: "port=82\n"} {
+        if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+            t.Fatal(err)
+        }
+    }
+    embedded := fstest.MapFS{"defaults": {Data: []byte("port=83\n")}}
+
+    tests := []struct {
+        args    []string
+        want    int
+        wantErr bool
+    }{
+        {args: []string{}, want: 81},
+        {args: []string{"-config", other}, want: 82},
+        {args: []string{"-config=" + missing}, wantErr: true},
+        {args: []string{"-no-config"}, want: 83},
+        {args: []string{"-no-config", "-port=84"}, want: 84},
+    }
+    for _, test := range tests {
+        c := NewConfigoSet("test", 0, path)
+        c.SetOutput(ioutil.Discard)
+        c.EnableConfigFlags()
+        c.SetEmbeddedDefaults(embedded, "defaults")
+        port := c.Int("port", 80, "the port")
+        c.SetArguments(test.args)
+        err := c.Parse()
+        if test.wantErr {
+            if err == nil {
+                t.Errorf("%q: Parse succeeded", test.args)
+            }
+            if _, err := os.Stat(missing); err == nil {
+                t.Errorf("%q: the missing file was created", test.args)
+            }
+            continue
+        }
+        if err != nil || *port != test.want {
+            t.Errorf("%q: port %d: %v, want %d", test.args, *port, err, test.want)
+        }
+    }
+}
+
+func TestCreatePolicy(t *testing.T) {
+    tests := []struct {
+        policy CreatePolicy
+        args   []string
+        create bool
+    }{
+        {CreateIfMissing, []string{}, true},
+        {NeverCreate, []string{}, false},
+        {CreateOnFlag, []string{}, false},
+        {CreateOnFlag, []string{"-write-default-config"}, true},
+    }
+    for _, test := range tests {
+        // The parent directories are created too.
+        path := filepath.Join(t.TempDir(), "a", "b", "rc")
+        c := NewConfigoSet("test", 0, path)
+        c.SetOutput(ioutil.Discard)
+        c.SetCreatePolicy(test.policy)
+        port := c.Int("port", 80, "the port")
+        c.SetArguments(test.args)
+        if err := c.Parse(); err != nil || *port != 80 {
+            t.Errorf("%v %q: port %d: %v", test.policy, test.args, *port, err)
+            continue
+        }
+
+        info, err := os.Stat(path)
+        if created := err == nil; created != test.create {
+            t.Errorf("%v %q: created %v, want %v", test.policy, test.args, created, test.create)
+            continue
+        }
+        if !test.create {
+            continue
+        }
+        if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+            t.Errorf("%v %q: mode %04o, want 0600", test.policy, test.args, info.Mode().Perm())
+        }
+        content, _ := ioutil.ReadFile(path)
+        if !strings.Contains(string(content), "port=80\n") {
+            t.Errorf("%v %q: wrote\n%s", test.policy, test.args, content)
+        }
+    }
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+    dir := t.TempDir()
+    path := filepath.Join(dir, "rc")
+    if err := ioutil.WriteFile(path, []byte("old\n"), 0600); err != nil {
+        t.Fatal(err)
+    }
+
+    // A failed write leaves the file as it was, and no temporary file.
+    failed := errors.New("failed")
+    err := writeFileAtomic(path, 0600, func(w io.Writer) error {
+        io.WriteString(w, "new\n")
+        return failed
+    })
+    if err != failed {
+        t.Errorf("writeFileAtomic = %v, want %v", err, failed)
+    }
+    if content, _ := ioutil.ReadFile(path); string(content) != "old\n" {
+        t.Errorf("file holds %q after a failed write", content)
+    }
+    if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+        t.Errorf("%d files left after a failed write", len(files))
+    }
+
+    if err := writeFileAtomic(path, 0600, func(w io.Writer) error {
+        _, err := io.WriteString(w, "new\n")
+        return err
+    }); err != nil {
+        t.Errorf("writeFileAtomic: %v", err)
+    }
+    if content, _ := ioutil.ReadFile(path); string(content) != "new\n" {
+        t.Errorf("file holds %q, want %q", content, "new\n")
+    }
+
+    if err := writeFileAtomic("", 0600, func(io.Writer) error { return nil }); err == nil {
+        t.Errorf("writeFileAtomic with no name succeeded")
+    }
+}
+
+func TestWriteOptions(t *testing.T) {
+    tests := []struct {
+        opts     WriteOptions
+        want     []string
+        unwanted []string
+    }{
+        {WriteOptions{}, []string{"\nport=80\n"}, []string{"verbose", "#port"}},
+        {WriteOptions{IncludeFlagOnly: true}, []string{"\nport=80\n", "\nverbose=false\n"}, nil},
+        {WriteOptions{CommentOut: true}, []string{"# the port\n#port=80\n"}, []string{"\nport", "verbose"}},
+        {WriteOptions{IncludeFlagOnly: true, CommentOut: true}, []string{"#port=80\n", "#verbose=false\n"}, []string{"\nport", "\nverbose"}},
+    }
+    for _, test := range tests {
+        c := NewConfigoSet("test", 0, "")
+        c.IntConfig("port", 80, "the port")
+        c.BoolFlag("verbose", false, "print more")
+        c.SetWriteOptions(test.opts)
+        var b bytes.Buffer
+        if err := c.WriteDefaultConfigTo(&b); err != nil {
+            t.Errorf("%+v: WriteDefaultConfigTo: %v", test.opts, err)
+            continue
+        }
+        for _, want := range test.want {
+            if !strings.Contains(b.String(), want) {
+                t.Errorf("%+v: %q is missing from\n%s", test.opts, want, b.String())
+            }
+        }
+        for _, unwanted := range test.unwanted {
+            if strings.Contains(b.String(), unwanted) {
+                t.Errorf("%+v: %q is in\n%s", test.opts, unwanted, b.String())
+            }
+        }
+    }
+}
+
+func TestWriteDefaultConfigPath(t *testing.T) {
+    dir := t.TempDir()
+    path := filepath.Join(dir, "rc")
+    c := NewConfigoSet("test", 0, path)
+    c.IntConfig("port", 80, "the port")
+
+    // The file is written to the path given, in the format of its extension.
+    out := filepath.Join(dir, "out.toml")
+    if err := c.WriteDefaultConfig(out); err != nil {
+        t.Fatalf("WriteDefaultConfig: %v", err)
+    }
+    if content, err := ioutil.ReadFile(out); err != nil || !strings.Contains(string(content), "\nport = 80\n") {
+        t.Errorf("%s holds\n%s: %v", out, content, err)
+    }
+    if _, err := os.Stat(path); err == nil {
+        t.Errorf("WriteDefaultConfig wrote the configuration file %s", path)
+    }
+}
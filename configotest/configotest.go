@@ -0,0 +1,156 @@
+// Package configotest helps to test programs and packages which use configo,
+// without touching the real home directory, configuration file or os.Args.
+//
+// A test creates a Set, which is a ConfigoSet whose configuration file is in
+// a temporary directory, registers its items, writes a fixture configuration
+// file, sets the command line and parses them:
+//
+//	func TestPort(t *testing.T) {
+//	    s := configotest.New(t, "prog")
+//	    s.Int("port", 8080, "the port to listen on")
+//	    s.WriteConfig("port=9090\n")
+//	    s.SetArgs("-port", "7070")
+//	    s.MustParse()
+//	    s.AssertValue("port", "7070")
+//	    s.AssertSource("port", "flag")
+//	}
+//
+// Everything is undone when the test ends.  Since Setenv changes the
+// environment of the whole process, tests using it cannot run in parallel.
+package configotest
+
+import (
+    "bytes"
+    "flag"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "testing"
+
+    "github.com/quincy/configo"
+)
+
+// Set is a ConfigoSet for a test.
+type Set struct {
+    *configo.ConfigoSet
+
+    // Dir is the temporary directory holding the configuration file.
+    Dir string
+
+    // Path is the path of the configuration file in Dir.
+    Path string
+
+    // Output holds the usage and error messages and warnings written by the
+    // set.
+    Output *bytes.Buffer
+
+    t testing.TB
+}
+
+// New returns a Set with the given name for the test t.  Its configuration
+// file is Path in a new temporary directory, which is removed when the test
+// ends.  The set returns errors rather than exiting, never writes a default
+// configuration file, and parses no arguments until SetArgs is called.  New
+// changes no global state, so tests using it may run in parallel.
+func New(t testing.TB, name string) *Set {
+    t.Helper()
+    dir := t.TempDir()
+
+    s := &Set{
+        Dir:    dir,
+        Path:   filepath.Join(dir, "."+name+"rc"),
+        Output: new(bytes.Buffer),
+        t:      t,
+    }
+    s.ConfigoSet = configo.NewConfigoSet(name, flag.ContinueOnError, s.Path)
+    s.SetOutput(s.Output)
+    s.SetArguments(nil)
+    s.SetCreatePolicy(configo.NeverCreate)
+    return s
+}
+
+// WriteConfig writes content to the configuration file, replacing it if it
+// already exists.
+func (s *Set) WriteConfig(content string) {
+    s.t.Helper()
+    s.WriteFile(filepath.Base(s.Path), content)
+}
+
+// WriteFile writes content to the named file in Dir, such as a secret or
+// key file, and returns its path.  The file may only be read by its owner.
+func (s *Set) WriteFile(name, content string) string {
+    s.t.Helper()
+    path := filepath.Join(s.Dir, name)
+    if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+        s.t.Fatal(err)
+    }
+    if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+        s.t.Fatal(err)
+    }
+    return path
+}
+
+// SetArgs sets the command line arguments parsed by Parse, without the
+// program name.
+func (s *Set) SetArgs(args ...string) {
+    s.SetArguments(args)
+}
+
+// Setenv sets an environment variable, which is restored when the test ends.
+func (s *Set) Setenv(key, value string) {
+    s.t.Helper()
+    s.t.Setenv(key, value)
+}
+
+// MustParse parses the command line and configuration file, and fails the
+// test if that fails.
+func (s *Set) MustParse() {
+    s.t.Helper()
+    if err := s.Parse(); err != nil {
+        s.t.Fatalf("Parse: %v\n%s", err, s.Output)
+    }
+}
+
+// ParseError parses the command line and configuration file, and fails the
+// test unless that fails.  It returns the error.
+func (s *Set) ParseError() error {
+    s.t.Helper()
+    err := s.Parse()
+    if err == nil {
+        s.t.Fatalf("Parse succeeded, but an error was expected")
+    }
+    return err
+}
+
+// AssertValue checks that the named item has the value want, as written by
+// the String method of its Value.
+func (s *Set) AssertValue(name, want string) {
+    s.t.Helper()
+    if got := s.lookup(name).Value.String(); got != want {
+        s.t.Errorf("%s = %q, want %q", name, got, want)
+    }
+}
+
+// AssertSource checks that the value of the named item came from want, as
+// returned by its Source method, such as "default", "flag" or FileSource().
+func (s *Set) AssertSource(name, want string) {
+    s.t.Helper()
+    if got := s.lookup(name).Source(); got != want {
+        s.t.Errorf("%s came from %q, want %q", name, got, want)
+    }
+}
+
+// FileSource returns the source of values from the configuration file.
+func (s *Set) FileSource() string {
+    return "file:" + s.Path
+}
+
+// lookup returns the named item, failing the test if there is none.
+func (s *Set) lookup(name string) *configo.Configo {
+    s.t.Helper()
+    config := s.Lookup(name)
+    if config == nil {
+        s.t.Fatalf("no configuration item %s", name)
+    }
+    return config
+}
@@ -0,0 +1,202 @@
+package configotest_test
+
+import (
+    "fmt"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "runtime"
+    "strings"
+    "testing"
+
+    "github.com/quincy/configo/configotest"
+)
+
+// fakeTB is a testing.TB which records failures instead of reporting them,
+// so that the failures of the helpers can be tested.
+type fakeTB struct {
+    testing.TB
+    cleanups []func()
+    errors   []string
+    fatal    bool
+}
+
+func (t *fakeTB) Helper() {}
+
+func (t *fakeTB) TempDir() string {
+    dir, err := ioutil.TempDir("", "configotest")
+    if err != nil {
+        panic(err)
+    }
+    t.Cleanup(func() { os.RemoveAll(dir) })
+    return dir
+}
+
+func (t *fakeTB) Cleanup(f func()) {
+    t.cleanups = append(t.cleanups, f)
+}
+
+func (t *fakeTB) Errorf(format string, args ...interface{}) {
+    t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeTB) Fatal(args ...interface{}) {
+    t.Fatalf("%s", fmt.Sprint(args...))
+}
+
+// Fatalf records the failure and stops the goroutine, as testing.T does.
+func (t *fakeTB) Fatalf(format string, args ...interface{}) {
+    t.Errorf(format, args...)
+    t.fatal = true
+    runtime.Goexit()
+}
+
+// run calls f with t in a new goroutine, so that Fatal can stop it, and then
+// runs the cleanups.
+func (t *fakeTB) run(f func(t testing.TB)) {
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        f(t)
+    }()
+    <-done
+    t.cleanup()
+}
+
+func (t *fakeTB) cleanup() {
+    for i := len(t.cleanups) - 1; i >= 0; i-- {
+        t.cleanups[i]()
+    }
+    t.cleanups = nil
+}
+
+func TestNew(t *testing.T) {
+    t.Parallel()
+    home := os.Getenv("HOME")
+    s := configotest.New(t, "prog")
+    if filepath.Dir(s.Path) != s.Dir || filepath.Base(s.Path) != ".progrc" {
+        t.Errorf("Path %s, want .progrc in %s", s.Path, s.Dir)
+    }
+    if info, err := os.Stat(s.Dir); err != nil || !info.IsDir() {
+        t.Errorf("Dir %s is not a directory: %v", s.Dir, err)
+    }
+    if os.Getenv("HOME") != home {
+        t.Errorf("HOME changed to %s", os.Getenv("HOME"))
+    }
+
+    // Nothing is parsed from os.Args or written to the configuration file.
+    s.Int("port", 8080, "the port")
+    s.MustParse()
+    s.AssertValue("port", "8080")
+    s.AssertSource("port", "default")
+    if _, err := os.Stat(s.Path); !os.IsNotExist(err) {
+        t.Errorf("configuration file %s was written: %v", s.Path, err)
+    }
+}
+
+func TestHelpers(t *testing.T) {
+    tests := []struct {
+        desc      string
+        config    string
+        args      []string
+        check     func(t testing.TB, s *configotest.Set)
+        wantError string // in the recorded failures, "" for none
+        wantFatal bool
+    }{
+        {
+            desc:   "file",
+            config: "port=9090\n",
+            check: func(t testing.TB, s *configotest.Set) {
+                s.MustParse()
+                s.AssertValue("port", "9090")
+                s.AssertSource("port", s.FileSource())
+            },
+        },
+        {
+            desc:   "flag",
+            config: "port=9090\n",
+            args:   []string{"-port", "7070"},
+            check: func(t testing.TB, s *configotest.Set) {
+                s.MustParse()
+                s.AssertValue("port", "7070")
+                s.AssertSource("port", "flag")
+            },
+        },
+        {
+            desc:   "parse error",
+            config: "prot=9090\n",
+            check: func(t testing.TB, s *configotest.Set) {
+                if err := s.ParseError(); !strings.Contains(err.Error(), "prot") {
+                    t.Errorf("ParseError = %v, want the unknown item", err)
+                }
+            },
+        },
+        {
+            desc:      "MustParse fails",
+            config:    "prot=9090\n",
+            check:     func(t testing.TB, s *configotest.Set) { s.MustParse() },
+            wantError: "Parse: unknown configuration item prot",
+            wantFatal: true,
+        },
+        {
+            desc:      "ParseError succeeds",
+            config:    "port=9090\n",
+            check:     func(t testing.TB, s *configotest.Set) { s.ParseError() },
+            wantError: "Parse succeeded",
+            wantFatal: true,
+        },
+        {
+            desc:   "wrong source",
+            config: "port=9090\n",
+            check: func(t testing.TB, s *configotest.Set) {
+                s.MustParse()
+                s.AssertSource("port", "flag")
+            },
+            wantError: `port came from "file:`,
+        },
+        {
+            desc:   "wrong value",
+            config: "port=9090\n",
+            check: func(t testing.TB, s *configotest.Set) {
+                s.MustParse()
+                s.AssertValue("port", "80")
+            },
+            wantError: `port = "9090", want "80"`,
+        },
+        {
+            desc:   "unknown item",
+            config: "port=9090\n",
+            check: func(t testing.TB, s *configotest.Set) {
+                s.MustParse()
+                s.AssertSource("host", "default")
+            },
+            wantError: "no configuration item host",
+            wantFatal: true,
+        },
+    }
+    for _, test := range tests {
+        tb := new(fakeTB)
+        var dir string
+        tb.run(func(t testing.TB) {
+            s := configotest.New(t, "prog")
+            dir = s.Dir
+            s.Int("port", 8080, "the port")
+            s.WriteConfig(test.config)
+            if test.args != nil {
+                s.SetArgs(test.args...)
+            }
+            test.check(t, s)
+        })
+
+        failures := strings.Join(tb.errors, "\n")
+        if test.wantError == "" && failures != "" || !strings.Contains(failures, test.wantError) {
+            t.Errorf("%s: failures %q, want %q", test.desc, failures, test.wantError)
+        }
+        if tb.fatal != test.wantFatal {
+            t.Errorf("%s: fatal %v, want %v", test.desc, tb.fatal, test.wantFatal)
+        }
+        if _, err := os.Stat(dir); !os.IsNotExist(err) {
+            t.Errorf("%s: %s was not removed: %v", test.desc, dir, err)
+        }
+    }
+}
@@ -0,0 +1,21 @@
+package configotest_test
+
+import (
+    "testing"
+
+    "github.com/quincy/configo/configotest"
+)
+
+// The example is the body of a test such as func TestPort(t *testing.T), so
+// it is compiled but not run.
+func ExampleNew() {
+    var t *testing.T // the *testing.T of the test
+
+    s := configotest.New(t, "prog")
+    s.Int("port", 8080, "the port to listen on")
+    s.WriteConfig("port=9090\n")
+    s.SetArgs("-port", "7070")
+    s.MustParse()
+    s.AssertValue("port", "7070")
+    s.AssertSource("port", "flag")
+}
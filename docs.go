@@ -0,0 +1,166 @@
+package configo
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "strings"
+)
+
+// WriteManPage writes a man page for the program to w in roff, with the
+// NAME, SYNOPSIS, OPTIONS and FILES sections, and ENVIRONMENT when the
+// program reads environment variables.  The description is the one line
+// summary of the program in the NAME section.  The options are the
+// registered flags, and FILES lists the configuration file and the items it
+// may set.  The page has no date, so that it is the same each time it is
+// written.
+func (c *ConfigoSet) WriteManPage(w io.Writer, description string) error {
+    b := bufio.NewWriter(w)
+    data := c.usageData()
+
+    fmt.Fprintf(b, ".TH %s 1\n", roff(strings.ToUpper(c.name)))
+    fmt.Fprintf(b, ".SH NAME\n%s \\- %s\n", roff(c.name), roff(description))
+    fmt.Fprintf(b, ".SH SYNOPSIS\n.B %s\n%s\n", roff(c.name), roff(data.Synopsis))
+
+    if len(data.Arguments) > 0 {
+        fmt.Fprintf(b, ".SH ARGUMENTS\n")
+        for _, item := range data.Arguments {
+            fmt.Fprintf(b, ".TP\n.I %s\n%s\n", roff(item.Names), roff(item.Usage))
+        }
+    }
+
+    fmt.Fprintf(b, ".SH OPTIONS\n")
+    for _, group := range data.Flags {
+        if group.Name != "" {
+            fmt.Fprintf(b, ".SS %s\n", roff(group.Name))
+        }
+        for _, item := range group.Items {
+            fmt.Fprintf(b, ".TP\n\\fB%s\\fR", roff(item.Names))
+            if item.Type != "" {
+                fmt.Fprintf(b, " \\fI%s\\fR", item.Type)
+            }
+            fmt.Fprintf(b, "\n%s\n", roff(manUsage(item)))
+        }
+    }
+
+    if c.path != "" {
+        fmt.Fprintf(b, ".SH FILES\n.TP\n.I %s\n", roff(c.path))
+        fmt.Fprintf(b, "The configuration file, which is read after the command line.  ")
+        fmt.Fprintf(b, "Options given on the command line override the file.  It may set:\n")
+        fmt.Fprintf(b, ".RS\n")
+        for _, config := range c.fileConfigs(false) {
+            fmt.Fprintf(b, ".TP\n.B %s\n%s\n", roff(config.Name), roff(manUsage(UsageItem{
+                Usage:   config.Usage,
+                Default: defaultText(config),
+            })))
+        }
+        fmt.Fprintf(b, ".RE\n")
+    }
+
+    if env := c.environment(); len(env) > 0 {
+        fmt.Fprintf(b, ".SH ENVIRONMENT\n")
+        for _, v := range env {
+            fmt.Fprintf(b, ".TP\n.B %s\n%s\n", roff(v.Name), roff(v.Usage))
+        }
+    }
+    return b.Flush()
+}
+
+// WriteManPage writes a man page for the program to w in roff.
+func WriteManPage(w io.Writer, description string) error {
+    return configuration.WriteManPage(w, description)
+}
+
+// WriteMarkdown writes a reference of every item to w as a Markdown table,
+// with its flags, type, default value, whether it may be set in the
+// configuration file, and its usage text, followed by the path of the
+// configuration file and the environment variables the program reads.
+func (c *ConfigoSet) WriteMarkdown(w io.Writer) error {
+    b := bufio.NewWriter(w)
+    fmt.Fprintf(b, "# %s\n\n", c.name)
+    fmt.Fprintf(b, "| Name | Flag | Type | Default | Config file | Description |\n")
+    fmt.Fprintf(b, "| --- | --- | --- | --- | --- | --- |\n")
+    c.VisitAll(func(config *Configo) {
+        if config.Hidden || c.isDeprecated(config.Name) {
+            return
+        }
+        names, file := "", "no"
+        if config.IsFlag {
+            names = markdownCode(c.flagNames(config))
+        }
+        if config.IsConfig {
+            file = "yes"
+        }
+        typ := typeName(config.Value)
+        if typ == "" {
+            typ = "bool"
+        }
+        fmt.Fprintf(b, "| %s | %s | %s | %s | %s | %s |\n", markdownCode(config.Name), names, typ,
+            markdownCode(defaultText(config)), file, markdownCell(config.Usage))
+    })
+
+    if c.path != "" {
+        fmt.Fprintf(b, "\nConfiguration file: `%s`\n", c.path)
+    }
+    if env := c.environment(); len(env) > 0 {
+        fmt.Fprintf(b, "\n| Environment variable | Description |\n| --- | --- |\n")
+        for _, v := range env {
+            fmt.Fprintf(b, "| %s | %s |\n", markdownCode(v.Name), markdownCell(v.Usage))
+        }
+    }
+    return b.Flush()
+}
+
+// WriteMarkdown writes a reference of every item to w as a Markdown table.
+func WriteMarkdown(w io.Writer) error {
+    return configuration.WriteMarkdown(w)
+}
+
+// envVar is an environment variable read by a ConfigoSet.
+type envVar struct {
+    Name  string
+    Usage string
+}
+
+// environment returns the environment variables c reads, for documentation.
+func (c *ConfigoSet) environment() []envVar {
+    var env []envVar
+    if c.profileFlags {
+        env = append(env, envVar{
+            Name:  c.profileEnvVar(),
+            Usage: "the profile of the configuration file to use, unless -profile is given",
+        })
+    }
+    return env
+}
+
+// manUsage returns the usage text of item followed by its default value.
+func manUsage(item UsageItem) string {
+    if item.Default == "" {
+        return item.Usage
+    }
+    return fmt.Sprintf("%s (default %s)", item.Usage, item.Default)
+}
+
+// roff escapes text for roff: backslashes, dashes and a leading period or
+// apostrophe, which would start a request.
+func roff(text string) string {
+    text = strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(text)
+    if strings.HasPrefix(text, ".") || strings.HasPrefix(text, "'") {
+        text = `\&` + text
+    }
+    return text
+}
+
+// markdownCode returns text as Markdown inline code, or "" for empty text.
+func markdownCode(text string) string {
+    if text == "" {
+        return ""
+    }
+    return "`" + strings.ReplaceAll(text, "|", `\|`) + "`"
+}
+
+// markdownCell escapes text for a Markdown table cell.
+func markdownCell(text string) string {
+    return strings.ReplaceAll(strings.ReplaceAll(text, "|", `\|`), "\n", " ")
+}
@@ -0,0 +1,90 @@
+package configo
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+)
+
+// newDocsSet returns a set whose usage texts need escaping in roff and
+// Markdown.
+func newDocsSet() *ConfigoSet {
+    c := NewConfigoSet("prog", 0, "/etc/prog.conf")
+    c.String("log-file", "-", `the log file, such as C:\log, or - for standard error`)
+    c.Bool("verbose", false, ".print more")
+    c.IntConfig("workers", 4, "the number of workers | threads")
+    c.EnableProfiles()
+    return c
+}
+
+func TestWriteManPage(t *testing.T) {
+    var b bytes.Buffer
+    if err := newDocsSet().WriteManPage(&b, "serve gophers"); err != nil {
+        t.Fatal(err)
+    }
+    want := `.TH PROG 1
+.SH NAME
+prog \- serve gophers
+.SH SYNOPSIS
+.B prog
+[flags]
+.SH OPTIONS
+.TP
+\fB\-log\-file\fR \fIstring\fR
+the log file, such as C:\elog, or \- for standard error (default "\-")
+.TP
+\fB\-profile\fR \fIstring\fR
+the profile of the configuration file to use
+.TP
+\fB\-verbose\fR
+\&.print more
+.SH FILES
+.TP
+.I /etc/prog.conf
+The configuration file, which is read after the command line.  Options given on the command line override the file.  It may set:
+.RS
+.TP
+.B log\-file
+the log file, such as C:\elog, or \- for standard error (default "\-")
+.TP
+.B verbose
+\&.print more
+.TP
+.B workers
+the number of workers | threads (default 4)
+.RE
+.SH ENVIRONMENT
+.TP
+.B PROG_PROFILE
+the profile of the configuration file to use, unless \-profile is given
+`
+    if b.String() != want {
+        t.Errorf("WriteManPage wrote\n%s\nwant\n%s", b.String(), want)
+    }
+}
+
+func TestWriteMarkdown(t *testing.T) {
+    var b bytes.Buffer
+    if err := newDocsSet().WriteMarkdown(&b); err != nil {
+        t.Fatal(err)
+    }
+    // The code spans are written with ' here.
+    want := strings.ReplaceAll(`# prog
+
+| Name | Flag | Type | Default | Config file | Description |
+| --- | --- | --- | --- | --- | --- |
+| 'log-file' | '-log-file' | string | '"-"' | yes | the log file, such as C:\log, or - for standard error |
+| 'profile' | '-profile' | string |  | no | the profile of the configuration file to use |
+| 'verbose' | '-verbose' | bool |  | yes | .print more |
+| 'workers' |  | int | '4' | yes | the number of workers \| threads |
+
+Configuration file: '/etc/prog.conf'
+
+| Environment variable | Description |
+| --- | --- |
+| 'PROG_PROFILE' | the profile of the configuration file to use, unless -profile is given |
+`, "'", "`")
+    if b.String() != want {
+        t.Errorf("WriteMarkdown wrote\n%s\nwant\n%s", b.String(), want)
+    }
+}
@@ -0,0 +1,170 @@
+package configo
+
+import (
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/rand"
+    "encoding/base64"
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "strings"
+)
+
+// EncryptedPrefix starts a value in the configuration file which is
+// encrypted, such as "enc:3q2+7w...".  The rest of the value is the base64
+// encoding of the AES-GCM nonce followed by the sealed value.  The name of the
+// item is authenticated with the value, so an encrypted value cannot be moved
+// to another item.
+const EncryptedPrefix = "enc:"
+
+// KeySize is the size in bytes of the AES-256 keys used for encrypted values.
+const KeySize = 32
+
+// SetKeyFile sets the path of the key file used to decrypt the encrypted
+// values in the configuration file.  The key file holds a base64 encoded key,
+// as written by WriteKeyFile.  It is only read when the configuration file
+// has an encrypted value, and then it must exist.
+func (c *ConfigoSet) SetKeyFile(path string) {
+    c.keyFile = path
+    c.key = nil
+}
+
+// SetKeyFile sets the path of the key file used to decrypt the encrypted
+// values in the configuration file.
+func SetKeyFile(path string) {
+    configuration.SetKeyFile(path)
+}
+
+// GenerateKey returns a new random key for encrypting values.
+func GenerateKey() ([]byte, error) {
+    key := make([]byte, KeySize)
+    if _, err := rand.Read(key); err != nil {
+        return nil, err
+    }
+    return key, nil
+}
+
+// WriteKeyFile writes a new random key to path, which only the current user
+// may read.  It fails if path already exists, so a key in use is never lost.
+func WriteKeyFile(path string) error {
+    key, err := GenerateKey()
+    if err != nil {
+        return err
+    }
+    file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+    if err != nil {
+        return err
+    }
+    _, err = fmt.Fprintln(file, base64.StdEncoding.EncodeToString(key))
+    if cerr := file.Close(); err == nil {
+        err = cerr
+    }
+    return err
+}
+
+// ReadKeyFile reads the key in the key file at path.
+func ReadKeyFile(path string) ([]byte, error) {
+    content, err := ioutil.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+    if err != nil || len(key) != KeySize {
+        return nil, fmt.Errorf("%s does not hold a %d byte base64 encoded key", path, KeySize)
+    }
+    return key, nil
+}
+
+// Encrypt encrypts the value of the named item with key, returning it as
+// written in the configuration file, starting with EncryptedPrefix.  The
+// name of an item of a subcommand is qualified by its section, such as
+// "serve.password".
+func Encrypt(key []byte, name, value string) (string, error) {
+    gcm, err := newGCM(key)
+    if err != nil {
+        return "", err
+    }
+    nonce := make([]byte, gcm.NonceSize())
+    if _, err := rand.Read(nonce); err != nil {
+        return "", err
+    }
+    sealed := gcm.Seal(nonce, nonce, []byte(value), []byte(name))
+    return EncryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value of the named item written by Encrypt with key.
+func Decrypt(key []byte, name, value string) (string, error) {
+    if !strings.HasPrefix(value, EncryptedPrefix) {
+        return "", fmt.Errorf("value does not start with %q", EncryptedPrefix)
+    }
+    sealed, err := base64.StdEncoding.DecodeString(value[len(EncryptedPrefix):])
+    if err != nil {
+        return "", errors.New("value is not base64 encoded")
+    }
+    gcm, err := newGCM(key)
+    if err != nil {
+        return "", err
+    }
+    if len(sealed) < gcm.NonceSize() {
+        return "", errors.New("value is too short")
+    }
+    nonce, sealed := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+    plain, err := gcm.Open(nil, nonce, sealed, []byte(name))
+    if err != nil {
+        return "", errors.New("value cannot be decrypted with the key for this item")
+    }
+    return string(plain), nil
+}
+
+// newGCM returns AES-GCM with key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+    if len(key) != KeySize {
+        return nil, fmt.Errorf("key is %d bytes, not %d", len(key), KeySize)
+    }
+    block, err := aes.NewCipher(key)
+    if err != nil {
+        return nil, err
+    }
+    return cipher.NewGCM(block)
+}
+
+// decrypt returns the value of config, named name in the configuration file,
+// given as an encrypted value, reading the key file the first time it is
+// needed.
+func (c *ConfigoSet) decrypt(name string, config *Configo, value string) (string, error) {
+    if c.key == nil {
+        if c.keyFile == "" {
+            return "", errors.New("no key file is set to decrypt it")
+        }
+        key, err := ReadKeyFile(c.keyFile)
+        if err != nil {
+            return "", err
+        }
+        c.key = key
+    }
+    plain, err := Decrypt(c.key, name, value)
+    if err != nil {
+        return "", err
+    }
+
+    // A secret starting with "@" would be read from a file.
+    if isSecret(config) && strings.HasPrefix(plain, "@") {
+        plain = "@" + plain
+    }
+    return plain, nil
+}
+
+// qualifiedName returns the name of the item of c in the configuration file
+// of its root set, such as "serve.password" for the subcommand serve.
+func (c *ConfigoSet) qualifiedName(name string) string {
+    for set := c; set.parent != nil; set = set.parent {
+        for section, sub := range set.parent.commands {
+            if sub == set {
+                name = section + "." + name
+            }
+        }
+    }
+    return name
+}
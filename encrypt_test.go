@@ -0,0 +1,107 @@
+package configo
+
+import (
+    "io/ioutil"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestEncryptRoundTrip(t *testing.T) {
+    key, err := GenerateKey()
+    if err != nil {
+        t.Fatal(err)
+    }
+    other, err := GenerateKey()
+    if err != nil {
+        t.Fatal(err)
+    }
+    encrypted, err := Encrypt(key, "password", "hunter2")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !strings.HasPrefix(encrypted, EncryptedPrefix) {
+        t.Fatalf("Encrypt = %q, want prefix %q", encrypted, EncryptedPrefix)
+    }
+
+    tests := []struct {
+        desc    string
+        key     []byte
+        name    string
+        value   string
+        want    string
+        wantErr bool
+    }{
+        {desc: "round trip", key: key, name: "password", value: encrypted, want: "hunter2"},
+        {desc: "other item", key: key, name: "token", value: encrypted, wantErr: true},
+        {desc: "other key", key: other, name: "password", value: encrypted, wantErr: true},
+        {desc: "short key", key: key[:16], name: "password", value: encrypted, wantErr: true},
+        {desc: "no prefix", key: key, name: "password", value: encrypted[len(EncryptedPrefix):], wantErr: true},
+        {desc: "not base64", key: key, name: "password", value: EncryptedPrefix + "!!", wantErr: true},
+        {desc: "too short", key: key, name: "password", value: EncryptedPrefix + "AAAA", wantErr: true},
+        {desc: "tampered", key: key, name: "password", value: encrypted[:len(encrypted)-4] + "AAA=", wantErr: true},
+    }
+    for _, test := range tests {
+        got, err := Decrypt(test.key, test.name, test.value)
+        if (err != nil) != test.wantErr || got != test.want {
+            t.Errorf("%s: Decrypt = %q, %v, want %q, error %v", test.desc, got, err, test.want, test.wantErr)
+        }
+    }
+}
+
+func TestEncryptedValues(t *testing.T) {
+    dir := t.TempDir()
+    keyFile := filepath.Join(dir, "key")
+    if err := WriteKeyFile(keyFile); err != nil {
+        t.Fatal(err)
+    }
+    if err := WriteKeyFile(keyFile); err == nil {
+        t.Error("WriteKeyFile replaced an existing key file")
+    }
+    key, err := ReadKeyFile(keyFile)
+    if err != nil {
+        t.Fatal(err)
+    }
+    encrypt := func(name, value string) string {
+        encrypted, err := Encrypt(key, name, value)
+        if err != nil {
+            t.Fatal(err)
+        }
+        return encrypted
+    }
+
+    tests := []struct {
+        desc    string
+        content string
+        token   string
+        serve   string
+        wantErr bool
+    }{
+        {desc: "values", content: "token=" + encrypt("token", "hunter2") + "\nserve.password=" + encrypt("serve.password", "@home") + "\n",
+            token: "hunter2", serve: "@home"},
+        {desc: "moved value", content: "token=" + encrypt("serve.password", "hunter2") + "\n", wantErr: true},
+        {desc: "not encrypted", content: "token=hunter2\n", token: "hunter2"},
+    }
+    for _, test := range tests {
+        path := filepath.Join(dir, "rc")
+        if err := ioutil.WriteFile(path, []byte(test.content), 0600); err != nil {
+            t.Fatal(err)
+        }
+        root := NewCommand("prog", 0, path)
+        root.SetOutput(ioutil.Discard)
+        root.SetArguments([]string{})
+        root.SetKeyFile(keyFile)
+        token := root.Secret("token", "the token")
+        serve := root.AddCommand("serve", "serve it", nil)
+        password := serve.Secret("password", "the password")
+
+        err := root.Parse()
+        if (err != nil) != test.wantErr {
+            t.Errorf("%s: Parse error %v, want error %v", test.desc, err, test.wantErr)
+            continue
+        }
+        if err == nil && (*token != test.token || *password != test.serve) {
+            t.Errorf("%s: token %q password %q, want %q %q", test.desc, *token, *password, test.token, test.serve)
+        }
+    }
+}
@@ -12,9 +12,9 @@ import (
 // Example 1: A single string flag called "species" with default value "gopher".
 var species = configo.String("species", "gopher", "the species we are studying")
 
-// Example 2: Two flags sharing a variable, so we can have a shorthand.
-// The order of initialization is undefined, so make sure both use the
-// same default value. They must be set up with an init function.
+// Example 2: A flag with a shorthand, so -g and -gopher_type both set it.
+// The shorthand is not valid in the config file.  It must be added after the
+// flag is defined, so set them up with an init function.
 var gopherType string
 
 func init() {
@@ -23,8 +23,7 @@ func init() {
         usage         = "the variety of gopher"
     )
     configo.StringVar(&gopherType, "gopher_type", defaultGopher, usage)
-    // shorthand version is not valid in the config file
-    configo.StringVar(&gopherType, "g", defaultGopher, usage+" (shorthand)")
+    configo.Shorthand("gopher_type", "g")
 }
 
 // Example 3: A user-defined flag type, a slice of durations.
@@ -80,6 +79,10 @@ func init() {
 }
 
 func main() {
+    // Let the user choose the config file with -config or skip it with
+    // -no-config.
+    configo.EnableConfigFlags()
+
     if err := configo.Parse(); err != nil {
         panic(err)
     }
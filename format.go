@@ -0,0 +1,257 @@
+package configo
+
+import (
+    "fmt"
+    "io"
+    "io/ioutil"
+    "path/filepath"
+    "strings"
+)
+
+// Format reads and writes one configuration file syntax.  Formats are
+// registered by name with RegisterFormat and chosen either explicitly with
+// SetFormat or by the extension of the configuration file.
+type Format interface {
+    // Decode reads the key/value pairs of a configuration file in the order
+    // they appear.
+    Decode(r io.Reader) ([]Entry, error)
+
+    // Encode writes configs, with their default values and usage text, as a
+    // configuration file.
+    Encode(w io.Writer, configs []*Configo) error
+}
+
+// Commenter is implemented by formats that support comment lines.  Comment
+// lines start with the returned prefix.
+type Commenter interface {
+    CommentPrefix() string
+}
+
+// Editor is implemented by formats that can change the value of one item in
+// an existing configuration file while preserving everything else in it, such
+// as comments, blank lines, ordering and unrelated keys.  Edit returns the new
+// content of the file; a missing item is added.
+type Editor interface {
+    Edit(content []byte, config *Configo, value string) ([]byte, error)
+}
+
+// Entry is a single key/value pair read from a configuration file.  Line is
+// the line of the file where the entry starts, or zero if it is not known.
+type Entry struct {
+    Name  string
+    Value string
+    Line  int
+}
+
+// The registered formats by name, and the format names by file extension.
+var formats = make(map[string]Format)
+var formatExtensions = make(map[string]string)
+
+// defaultFormat is the name of the format used when no format has been set
+// and the configuration file's extension is not registered.
+const defaultFormat = "keyvalue"
+
+func init() {
+    RegisterFormat(defaultFormat, &KeyValueFormat{})
+    RegisterFormat("toml", tomlFormat{}, ".toml")
+    RegisterFormat("yaml", yamlFormat{}, ".yaml", ".yml")
+}
+
+// RegisterFormat makes a format available by name, and optionally for
+// configuration files with any of the given extensions, such as ".json".
+// RegisterFormat panics if a format is registered twice with the same name.
+func RegisterFormat(name string, format Format, extensions ...string) {
+    if _, alreadythere := formats[name]; alreadythere {
+        panic(fmt.Sprintf("configo: format registered twice: %s", name))
+    }
+    formats[name] = format
+    for _, ext := range extensions {
+        formatExtensions[strings.ToLower(ext)] = name
+    }
+}
+
+// LookupFormat returns the format registered with name, returning nil if
+// none exists.
+func LookupFormat(name string) Format {
+    return formats[name]
+}
+
+// SetFormat sets the name of the format used to read and write the
+// configuration file, overriding the choice by file extension.
+func (c *ConfigoSet) SetFormat(name string) {
+    c.format = name
+}
+
+// SetFormat sets the name of the format used to read and write the
+// configuration file, overriding the choice by file extension.
+func SetFormat(name string) {
+    configuration.SetFormat(name)
+}
+
+// formatFor returns the format used for the configuration file at path.  A
+// key/value format without a delimiter, such as the built-in one, uses the
+// delimiter of c.
+func (c *ConfigoSet) formatFor(path string) (Format, error) {
+    name := c.format
+    if name == "" {
+        name = formatExtensions[strings.ToLower(filepath.Ext(path))]
+    }
+    if name == "" {
+        name = defaultFormat
+    }
+    format, ok := formats[name]
+    if !ok {
+        return nil, fmt.Errorf("unknown configuration format %s", name)
+    }
+    if kv, ok := format.(*KeyValueFormat); ok && kv.Delimiter == "" {
+        return &KeyValueFormat{Delimiter: c.delimiter}, nil
+    }
+    return format, nil
+}
+
+// KeyValueFormat is the original configo format.  Each line is a key/value
+// pair separated by Delimiter.  Blank lines and lines where the first
+// non-whitespace character is '#' are ignored.  A line such as "[serve]"
+// starts a section; the keys after it are prefixed with "serve.", so "port"
+// in that section sets the item "serve.port".  If Delimiter is empty, as in
+// the built-in "keyvalue" format, the one set with SetDelimiter is used.
+type KeyValueFormat struct {
+    Delimiter string
+}
+
+// delimiter returns the delimiter of the key/value pairs.
+func (f *KeyValueFormat) delimiter() string {
+    if f.Delimiter == "" {
+        return configuration.delimiter
+    }
+    return f.Delimiter
+}
+
+// kvSection returns the name of the section started by line, which has been
+// trimmed, and whether it is a section header at all.
+func kvSection(line string) (string, bool) {
+    if len(line) < 2 || line[0] != '[' || line[len(line)-1] != ']' {
+        return "", false
+    }
+    return strings.TrimSpace(line[1 : len(line)-1]), true
+}
+
+// kvName returns the item name of key in section.
+func kvName(section, key string) string {
+    if section == "" {
+        return key
+    }
+    return section + "." + key
+}
+
+// Decode reads the key/value pairs from r.
+func (f *KeyValueFormat) Decode(r io.Reader) ([]Entry, error) {
+    content, err := ioutil.ReadAll(r)
+    if err != nil {
+        return nil, err
+    }
+
+    var entries []Entry
+    section := ""
+    for i, line := range strings.Split(string(content), "\n") {
+        line = strings.TrimSpace(line)
+
+        if name, ok := kvSection(line); ok {
+            section = name
+        } else if len(line) > 0 && !strings.HasPrefix(line, "#") {
+            fields := strings.SplitN(line, f.delimiter(), 2)
+            if len(fields) != 2 {
+                return nil, fmt.Errorf("line %d: invalid key%svalue pair", i+1, f.delimiter())
+            }
+            entries = append(entries, Entry{
+                Name:  kvName(section, strings.TrimSpace(fields[0])),
+                Value: strings.TrimSpace(fields[1]),
+                Line:  i + 1,
+            })
+        }
+    }
+    return entries, nil
+}
+
+// Encode writes each config as a usage comment followed by its key/value pair.
+func (f *KeyValueFormat) Encode(w io.Writer, configs []*Configo) error {
+    for _, config := range configs {
+        format := "# %s\n%s%s%s\n\n"
+        if _, err := fmt.Fprintf(w, format, config.Usage, config.Name, f.delimiter(), config.DefaultValue); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// CommentPrefix returns the prefix of a comment line.
+func (f *KeyValueFormat) CommentPrefix() string {
+    return "#"
+}
+
+// Edit sets the value of config in content.  The last line setting config is
+// changed in place, keeping the spacing around the delimiter.  If there is no
+// such line, one is added at the end of the longest section the name of
+// config starts with, or else at the end of the lines before any section.
+func (f *KeyValueFormat) Edit(content []byte, config *Configo, value string) ([]byte, error) {
+    if strings.ContainsAny(value, "\r\n") {
+        return nil, fmt.Errorf("the value of %s cannot span lines", config.Name)
+    }
+
+    s := string(content)
+    if s != "" && !strings.HasSuffix(s, "\n") {
+        s += "\n"
+    }
+    lines := strings.SplitAfter(s, "\n")
+    lines = lines[:len(lines)-1]
+
+    // Find the last line setting config, and where each section ends: after
+    // its last non-blank line.  The root section, before any header, is "".
+    last := -1
+    ends := map[string]int{"": 0}
+    sections := false
+    current := ""
+    for i, line := range lines {
+        line = strings.TrimSpace(line)
+        if name, ok := kvSection(line); ok {
+            current = name
+            sections = true
+        } else if len(line) > 0 && !strings.HasPrefix(line, "#") {
+            fields := strings.SplitN(line, f.delimiter(), 2)
+            if len(fields) == 2 && kvName(current, strings.TrimSpace(fields[0])) == config.Name {
+                last = i
+            }
+        }
+        if len(line) > 0 {
+            ends[current] = i + 1
+        }
+    }
+
+    if last < 0 {
+        best, at := "", len(lines)
+        if sections {
+            at = ends[""]
+        }
+        for section, end := range ends {
+            if len(section) > len(best) && strings.HasPrefix(config.Name, section+".") {
+                best, at = section, end
+            }
+        }
+        key := config.Name
+        if best != "" {
+            key = strings.TrimPrefix(key, best+".")
+        }
+        added := key + f.delimiter() + value + "\n"
+        lines = append(lines[:at], append([]string{added}, lines[at:]...)...)
+        return []byte(strings.Join(lines, "")), nil
+    }
+
+    line := lines[last]
+    delimiter := f.delimiter()
+    i := strings.Index(line, delimiter) + len(delimiter)
+    rest := line[i:]
+    space := rest[:len(rest)-len(strings.TrimLeft(rest, " \t"))]
+    ending := rest[len(strings.TrimRight(rest, "\r\n")):]
+    lines[last] = line[:i] + space + value + ending
+    return []byte(strings.Join(lines, "")), nil
+}
@@ -0,0 +1,194 @@
+package configo
+
+import (
+    "io/ioutil"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestKeyValueEdit(t *testing.T) {
+    c := NewConfigoSet("test", 0, "")
+    c.IntConfig("port", 80, "the port")
+    c.StringConfig("name", "", "the name")
+    c.StringConfig("serve.host", "", "the host")
+    c.StringConfig("serve.tls.cert", "", "the certificate")
+    format := &KeyValueFormat{Delimiter: "="}
+
+    tests := []struct {
+        desc  string
+        src   string
+        name  string
+        value string
+        want  string
+    }{
+        {"replace keeps comments", "# the port\nport = 80\n\n# the name\nname=a\n", "port", "90", "# the port\nport = 90\n\n# the name\nname=a\n"},
+        {"replace last", "port=1\nport=2\n", "port", "3", "port=1\nport=3\n"},
+        {"replace keeps CRLF", "port\t=  80\r\nname=a\r\n", "port", "90", "port\t=  90\r\nname=a\r\n"},
+        {"replace in section", "[serve]\nhost = h\n", "serve.host", "x", "[serve]\nhost = x\n"},
+        {"commented out", "#port=80\n", "port", "90", "#port=80\nport=90\n"},
+        {"add before sections", "port=80\n\n[serve]\nhost=h\n", "name", "n", "port=80\nname=n\n\n[serve]\nhost=h\n"},
+        {"add to section", "[serve]\nhost=h\n\n[other]\nx=1\n", "serve.tls.cert", "c", "[serve]\nhost=h\ntls.cert=c\n\n[other]\nx=1\n"},
+        {"add to longest section", "[serve]\nhost=h\n[serve.tls]\nkey=k\n", "serve.tls.cert", "c", "[serve]\nhost=h\n[serve.tls]\nkey=k\ncert=c\n"},
+        {"add without section", "port=80\n", "serve.host", "h", "port=80\nserve.host=h\n"},
+        {"add without newline", "port=80", "name", "n", "port=80\nname=n\n"},
+        {"empty", "", "port", "90", "port=90\n"},
+    }
+    for _, test := range tests {
+        got, err := format.Edit([]byte(test.src), c.Lookup(test.name), test.value)
+        if err != nil {
+            t.Errorf("%s: %v", test.desc, err)
+            continue
+        }
+        if string(got) != test.want {
+            t.Errorf("%s: got %q, want %q", test.desc, got, test.want)
+        }
+    }
+
+    if _, err := format.Edit([]byte("name=a\n"), c.Lookup("name"), "a\nb"); err == nil {
+        t.Error("Edit of a value with a newline succeeded")
+    }
+}
+
+func TestSaveValue(t *testing.T) {
+    for _, file := range []string{"rc", "rc.toml", "rc.yaml"} {
+        path := filepath.Join(t.TempDir(), file)
+        c := NewConfigoSet("test", 0, path)
+        port := c.IntConfig("port", 80, "the port")
+        name := c.StringConfig("name", "a", "the name")
+
+        if err := c.SaveValue("port", "x"); err == nil || *port != 80 {
+            t.Errorf("%s: SaveValue of an invalid value: %v, port %d", file, err, *port)
+        }
+        if err := c.SaveValue("name", "b\nc"); file == "rc" && (err == nil || *name != "a" || c.Lookup("name").Source() != "default") {
+            t.Errorf("%s: SaveValue of an unwritable value: %v, name %q from %s", file, err, *name, c.Lookup("name").Source())
+        }
+        if err := c.SaveValue("port", "90"); err != nil || *port != 90 || c.Lookup("port").Source() != "set" {
+            t.Errorf("%s: SaveValue: %v, port %d from %s", file, err, *port, c.Lookup("port").Source())
+        }
+
+        // A new set reads the saved value back.
+        r := NewConfigoSet("test", 0, path)
+        r.SetArguments([]string{})
+        port = r.IntConfig("port", 80, "the port")
+        r.StringConfig("name", "a", "the name")
+        if err := r.Parse(); err != nil || *port != 90 {
+            content, _ := ioutil.ReadFile(path)
+            t.Errorf("%s: read back port %d: %v\n%s", file, *port, err, content)
+        }
+    }
+}
+
+func TestSaveValueSubcommand(t *testing.T) {
+    for _, file := range []string{"rc", "rc.toml", "rc.yaml"} {
+        path := filepath.Join(t.TempDir(), file)
+        root := NewCommand("prog", 0, path)
+        serve := root.AddCommand("serve", "serve it", nil)
+        port := serve.IntConfig("port", 80, "the port")
+
+        if err := root.SaveValue("serve.port", "81"); err != nil || *port != 81 {
+            t.Errorf("%s: root SaveValue: %v, port %d", file, err, *port)
+        }
+        if err := serve.SaveValue("port", "82"); err != nil || *port != 82 || serve.Lookup("port").Source() != "set" {
+            t.Errorf("%s: subcommand SaveValue: %v, port %d from %s", file, err, *port, serve.Lookup("port").Source())
+        }
+
+        r := NewCommand("prog", 0, path)
+        r.SetArguments([]string{})
+        port = r.AddCommand("serve", "serve it", nil).IntConfig("port", 80, "the port")
+        if err := r.Parse(); err != nil || *port != 82 {
+            content, _ := ioutil.ReadFile(path)
+            t.Errorf("%s: read back port %d: %v\n%s", file, *port, err, content)
+        }
+    }
+
+    c := NewConfigoSet("test", 0, "")
+    c.IntConfig("port", 80, "the port")
+    if err := c.SaveValue("port", "81"); err == nil || err.Error() != "there is no configuration file to save the value in" {
+        t.Errorf("SaveValue without a file: %v", err)
+    }
+}
+
+// registerTestFormat registers format for the rest of the test.
+func registerTestFormat(t *testing.T, name string, format Format, extensions ...string) {
+    RegisterFormat(name, format, extensions...)
+    t.Cleanup(func() {
+        delete(formats, name)
+        for _, ext := range extensions {
+            delete(formatExtensions, ext)
+        }
+    })
+}
+
+func TestFormatSelection(t *testing.T) {
+    registerTestFormat(t, "colon", &KeyValueFormat{Delimiter: ":"}, ".conf")
+
+    tests := []struct {
+        file    string
+        format  string
+        content string
+        wantErr bool
+    }{
+        {file: "rc", content: "port=81\n"},
+        {file: "rc.ini", content: "port=81\n"},
+        {file: "rc.toml", content: "port = 81\n"},
+        {file: "rc.TOML", content: "port = 81\n"},
+        {file: "rc.yaml", content: "port: 81\n"},
+        {file: "rc.yml", content: "port: 81\n"},
+        {file: "rc.conf", content: "port: 81\n"},
+        {file: "rc.toml", format: "colon", content: "port: 81\n"},
+        {file: "rc.conf", format: "keyvalue", content: "port=81\n"},
+        {file: "rc", format: "nosuch", content: "port=81\n", wantErr: true},
+    }
+    for _, test := range tests {
+        path := filepath.Join(t.TempDir(), test.file)
+        if err := ioutil.WriteFile(path, []byte(test.content), 0600); err != nil {
+            t.Fatal(err)
+        }
+        c := NewConfigoSet("test", 0, path)
+        c.SetFormat(test.format)
+        port := c.IntConfig("port", 80, "the port")
+        c.SetArguments([]string{})
+        err := c.Parse()
+        if test.wantErr {
+            if err == nil {
+                t.Errorf("%s %q: Parse succeeded", test.file, test.format)
+            }
+            continue
+        }
+        if err != nil || *port != 81 {
+            t.Errorf("%s %q: port %d: %v", test.file, test.format, *port, err)
+        }
+    }
+}
+
+func TestRegisterFormat(t *testing.T) {
+    format := &KeyValueFormat{Delimiter: ":"}
+    registerTestFormat(t, "colon", format)
+    if LookupFormat("colon") != format {
+        t.Errorf("LookupFormat(colon) = %v, want %v", LookupFormat("colon"), format)
+    }
+    if LookupFormat("nosuch") != nil {
+        t.Errorf("LookupFormat(nosuch) = %v, want nil", LookupFormat("nosuch"))
+    }
+
+    func() {
+        defer func() {
+            if recover() == nil {
+                t.Errorf("registering keyvalue twice did not panic")
+            }
+        }()
+        RegisterFormat("keyvalue", format)
+    }()
+
+    // The built-in key/value format uses the delimiter set with SetDelimiter.
+    defer SetDelimiter(configuration.delimiter)
+    SetDelimiter(":")
+    entries, err := LookupFormat("keyvalue").Decode(strings.NewReader("port: 81\n"))
+    if err != nil || len(entries) != 1 || entries[0].Name != "port" || entries[0].Value != "81" {
+        t.Errorf("keyvalue with delimiter %q read %+v: %v", ":", entries, err)
+    }
+    if format, err := configuration.formatFor("rc"); err != nil || format.(*KeyValueFormat).Delimiter != ":" {
+        t.Errorf("formatFor(rc) = %+v: %v, want delimiter %q", format, err, ":")
+    }
+}
@@ -0,0 +1,128 @@
+package configo
+
+import (
+    "fmt"
+    "os"
+    "os/user"
+    "path"
+    "runtime"
+    "strings"
+)
+
+// The name of the configuration file sections which only apply on some
+// machines, such as "[match host=build-* os=linux]".
+const matchSection = "match"
+
+// matchBlock is the entries of one match section of a configuration file.
+type matchBlock struct {
+    condition string
+    line      int // the line of its first entry
+    entries   []Entry
+}
+
+// splitMatches splits entries into those outside any match section and the
+// blocks of entries in each, in the order they first appear, with names made
+// relative to the section.  A match section is "[match host=build-*]" in
+// the key/value format, the table [match."host=build-*"] in TOML and the
+// mapping match: "host=build-*": in YAML.  As host name patterns may have
+// dots, as in "[match host=*.example.com]", the condition is taken to end at
+// the first dot which leaves the name of an item.
+func (c *ConfigoSet) splitMatches(entries []Entry) ([]Entry, []matchBlock) {
+    var base []Entry
+    var blocks []matchBlock
+    index := make(map[string]int)
+    for _, e := range entries {
+        rest := ""
+        if _, config, _ := c.lookupEntry(e.Name); config == nil {
+            for _, prefix := range []string{matchSection + " ", matchSection + "."} {
+                if strings.HasPrefix(e.Name, prefix) {
+                    rest = e.Name[len(prefix):]
+                }
+            }
+        }
+
+        end := -1
+        for i := 0; i < len(rest); i++ {
+            if rest[i] == '.' {
+                end = i
+                if c.isEntryName(rest[i+1:]) {
+                    break
+                }
+            }
+        }
+        if end <= 0 {
+            base = append(base, e)
+            continue
+        }
+
+        condition := strings.Join(strings.Fields(rest[:end]), " ")
+        e.Name = rest[end+1:]
+        i, ok := index[condition]
+        if !ok {
+            i = len(blocks)
+            index[condition] = i
+            blocks = append(blocks, matchBlock{condition: condition, line: e.Line})
+        }
+        blocks[i].entries = append(blocks[i].entries, e)
+    }
+    return base, blocks
+}
+
+// isEntryName reports whether name may be set in the configuration file, or
+// names the file or command holding a secret.
+func (c *ConfigoSet) isEntryName(name string) bool {
+    if _, config, _ := c.lookupEntry(name); config != nil {
+        return true
+    }
+    _, config, _ := c.lookupSecretSource(name)
+    return config != nil
+}
+
+// matchCondition reports whether the condition of a match section holds on
+// this machine.  The condition is one or more tests separated by spaces, all
+// of which must hold: host=pattern, os=pattern, arch=pattern and
+// user=pattern test the host name, GOOS, GOARCH and user name against a
+// pattern in the syntax of path.Match, such as "build-*".
+func matchCondition(condition string) (bool, error) {
+    tests := strings.Fields(condition)
+    if len(tests) == 0 {
+        return false, fmt.Errorf("empty condition")
+    }
+    for _, test := range tests {
+        key, pattern, ok := strings.Cut(test, "=")
+        if !ok {
+            return false, fmt.Errorf("%s is not key=pattern", test)
+        }
+
+        var value string
+        switch key {
+        case "host":
+            host, err := os.Hostname()
+            if err != nil {
+                return false, err
+            }
+            value = host
+        case "os":
+            value = runtime.GOOS
+        case "arch":
+            value = runtime.GOARCH
+        case "user":
+            usr, err := user.Current()
+            if err != nil {
+                return false, err
+            }
+            value = usr.Username
+        default:
+            return false, fmt.Errorf("unknown key %s", key)
+        }
+
+        matched, err := path.Match(pattern, value)
+        if err != nil {
+            return false, fmt.Errorf("invalid pattern %s", pattern)
+        }
+        if !matched {
+            return false, nil
+        }
+    }
+    return true, nil
+}
@@ -0,0 +1,118 @@
+package configo
+
+import (
+    "io/ioutil"
+    "os"
+    "os/user"
+    "path/filepath"
+    "runtime"
+    "strings"
+    "testing"
+)
+
+func TestMatchCondition(t *testing.T) {
+    host, err := os.Hostname()
+    if err != nil {
+        t.Fatal(err)
+    }
+    usr, err := user.Current()
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        condition string
+        want      bool
+        wantErr   string
+    }{
+        {condition: "os=" + runtime.GOOS, want: true},
+        {condition: "os=no-such-os", want: false},
+        {condition: "arch=" + runtime.GOARCH, want: true},
+        {condition: "host=" + host, want: true},
+        {condition: "host=" + host[:1] + "*", want: true},
+        {condition: "host=?" + host, want: false},
+        {condition: "user=" + usr.Username, want: true},
+        {condition: "user=[^" + usr.Username[:1] + "]*", want: false},
+        {condition: "os=* arch=*", want: true},
+        {condition: "os=" + runtime.GOOS + " arch=no-such-arch", want: false},
+        {condition: "os=no-such-os arch=" + runtime.GOARCH, want: false},
+        {condition: "", wantErr: "empty condition"},
+        {condition: "os", wantErr: "os is not key=pattern"},
+        {condition: "colour=red", wantErr: "unknown key colour"},
+        {condition: "os=[", wantErr: "invalid pattern ["},
+    }
+    for _, test := range tests {
+        got, err := matchCondition(test.condition)
+        if test.wantErr != "" {
+            if err == nil || err.Error() != test.wantErr {
+                t.Errorf("%q: error %v, want %q", test.condition, err, test.wantErr)
+            }
+            continue
+        }
+        if err != nil || got != test.want {
+            t.Errorf("%q: %v, %v, want %v", test.condition, got, err, test.want)
+        }
+    }
+}
+
+func TestMatchSections(t *testing.T) {
+    goos := runtime.GOOS
+    tests := []struct {
+        desc    string
+        file    string
+        content string
+        args    []string
+        want    int
+        section string
+        wantErr string
+    }{
+        {desc: "applies", file: "rc", content: "port=1\n[match os=" + goos + "]\nport=2\n", want: 2, section: " [match os=" + goos + "]"},
+        {desc: "does not apply", file: "rc", content: "port=1\n[match os=no-such-os]\nport=2\n", want: 1},
+        {desc: "all tests", file: "rc", content: "[match os=" + goos + "  arch=no-such-arch]\nport=2\n", want: 80},
+        {desc: "later block wins", file: "rc", content: "[match os=*]\nport=2\n[match arch=*]\nport=3\n", want: 3, section: " [match arch=*]"},
+        {desc: "dotted pattern", file: "rc", content: "[match host=*.no-such.example.com]\nport=2\n", want: 80},
+        {desc: "flag wins", file: "rc", content: "[match os=*]\nport=2\n", args: []string{"-port", "4"}, want: 4},
+        {desc: "profile wins", file: "rc", content: "[profile prod]\nport=3\n[match os=*]\nport=2\n", args: []string{"-profile", "prod"}, want: 3, section: " [profile prod]"},
+        {desc: "toml", file: "rc.toml", content: "port = 1\n[match.\"os=" + goos + "\"]\nport = 2\n", want: 2, section: " [match os=" + goos + "]"},
+        {desc: "toml dotted pattern", file: "rc.toml", content: "[match.\"host=*.example.com os=*\"]\nport = 2\n", want: 80},
+        {desc: "yaml", file: "rc.yaml", content: "port: 1\nmatch:\n  \"os=" + goos + "\":\n    port: 2\n", want: 2, section: " [match os=" + goos + "]"},
+        {desc: "unknown item", file: "rc", content: "[match os=" + goos + "]\nprot=2\n", wantErr: "unknown configuration item prot"},
+        {desc: "unknown item where it does not apply", file: "rc", content: "[match os=no-such-os]\nprot=2\n", wantErr: "unknown configuration item prot in match section os=no-such-os"},
+        {desc: "unknown key", file: "rc", content: "[match colour=red]\nport=2\n", wantErr: "invalid condition colour=red"},
+        {desc: "invalid pattern", file: "rc", content: "[match os=[]\nport=2\n", wantErr: "invalid condition os=["},
+    }
+    for _, test := range tests {
+        path := filepath.Join(t.TempDir(), test.file)
+        if err := ioutil.WriteFile(path, []byte(test.content), 0600); err != nil {
+            t.Fatal(err)
+        }
+        c := NewConfigoSet("test", 0, path)
+        c.SetOutput(ioutil.Discard)
+        if test.args == nil {
+            test.args = []string{}
+        }
+        c.SetArguments(test.args)
+        c.EnableProfiles()
+        port := c.Int("port", 80, "the port")
+
+        err := c.Parse()
+        if test.wantErr != "" {
+            if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+                t.Errorf("%s: Parse error %v, want %q", test.desc, err, test.wantErr)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("%s: Parse: %v", test.desc, err)
+            continue
+        }
+        if *port != test.want {
+            t.Errorf("%s: port %d, want %d", test.desc, *port, test.want)
+        }
+        if test.section != "" {
+            if source := c.Lookup("port").Source(); source != "file:"+path+test.section {
+                t.Errorf("%s: source %q, want section %q", test.desc, source, test.section)
+            }
+        }
+    }
+}
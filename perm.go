@@ -0,0 +1,65 @@
+package configo
+
+import (
+    "fmt"
+    "os"
+)
+
+// PermissionPolicy controls what Parse does when the configuration file could
+// have been changed by other users, which could make the program read paths
+// and run commands of their choosing.
+type PermissionPolicy int
+
+// These constants cause Parse to behave as described if the configuration
+// file, or any directory above it up to the home directory or the root, is
+// writable by other users or owned by a user other than the current user or
+// root.
+const (
+    IgnoreInsecure PermissionPolicy = iota // Read it without checking.
+    WarnInsecure                           // Print a warning and read it.
+    RefuseInsecure                         // Return an error without reading it.
+)
+
+// SetPermissionPolicy sets what Parse does when the configuration file could
+// have been changed by other users.  The default is IgnoreInsecure.  The
+// checks are like those of ssh for its configuration files, and are only made
+// on Unix systems.
+func (c *ConfigoSet) SetPermissionPolicy(policy PermissionPolicy) {
+    c.permissions = policy
+}
+
+// SetPermissionPolicy sets what Parse does when the configuration file could
+// have been changed by other users.
+func SetPermissionPolicy(policy PermissionPolicy) {
+    configuration.SetPermissionPolicy(policy)
+}
+
+// checkPermissions applies the permission policy to the configuration file
+// opened from path before it is read.  The open file is checked, rather than
+// the path, so that it cannot be replaced between the check and the read.
+func (c *ConfigoSet) checkPermissions(file *os.File, path string) error {
+    if c.permissions == IgnoreInsecure {
+        return nil
+    }
+    reason, err := insecure(file, path)
+    if err != nil || reason == "" {
+        return err
+    }
+    if c.permissions == RefuseInsecure {
+        return fmt.Errorf("refusing to read %s: %s", path, reason)
+    }
+    fmt.Fprintf(c.out(), "warning: %s: %s\n", path, reason)
+    return nil
+}
+
+// insecureOwner returns why a file with info, owned by uid, could be changed
+// by users other than the current user and root, or "".
+func insecureOwner(info os.FileInfo, uid int) string {
+    if uid != os.Getuid() && uid != 0 {
+        return fmt.Sprintf("owned by uid %d", uid)
+    }
+    if info.Mode().Perm()&0022 != 0 && (!info.IsDir() || info.Mode()&os.ModeSticky == 0) {
+        return fmt.Sprintf("writable by other users (mode %04o)", info.Mode().Perm())
+    }
+    return ""
+}
@@ -0,0 +1,53 @@
+//go:build !windows && !plan9
+
+package configo
+
+import (
+    "os"
+    "path/filepath"
+    "syscall"
+)
+
+// insecure returns why the configuration file opened from path, or one of
+// the directories above it, could be changed by other users, or "" if it
+// could not.  As ssh does, the directories are checked up to the home
+// directory, or the root if the file is not in the home directory.
+func insecure(file *os.File, path string) (string, error) {
+    info, err := file.Stat()
+    if err != nil {
+        return "", err
+    }
+    if reason := insecureInfo(info); reason != "" {
+        return "it is " + reason, nil
+    }
+
+    dir, err := filepath.Abs(filepath.Dir(path))
+    if err != nil {
+        return "", err
+    }
+    home, _ := os.UserHomeDir()
+    for {
+        info, err := os.Stat(dir)
+        if err != nil {
+            return "", err
+        }
+        if reason := insecureInfo(info); reason != "" {
+            return "its directory " + dir + " is " + reason, nil
+        }
+        parent := filepath.Dir(dir)
+        if dir == home || parent == dir {
+            return "", nil
+        }
+        dir = parent
+    }
+}
+
+// insecureInfo returns why the file with info could be changed by other
+// users, or "".
+func insecureInfo(info os.FileInfo) string {
+    stat, ok := info.Sys().(*syscall.Stat_t)
+    if !ok {
+        return ""
+    }
+    return insecureOwner(info, int(stat.Uid))
+}
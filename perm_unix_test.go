@@ -0,0 +1,66 @@
+//go:build !windows && !plan9
+
+package configo
+
+import (
+    "bytes"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestPermissionPolicy(t *testing.T) {
+    tests := []struct {
+        desc     string
+        policy   PermissionPolicy
+        fileMode os.FileMode
+        dirMode  os.FileMode // of the directory above the one holding the file
+        wantErr  string
+        wantWarn string
+    }{
+        {desc: "private", policy: RefuseInsecure, fileMode: 0600, dirMode: 0755},
+        {desc: "writable file", policy: RefuseInsecure, fileMode: 0666, dirMode: 0755, wantErr: "it is writable by other users"},
+        {desc: "writable ancestor", policy: RefuseInsecure, fileMode: 0600, dirMode: 0777, wantErr: "its directory"},
+        {desc: "sticky ancestor", policy: RefuseInsecure, fileMode: 0600, dirMode: 0777 | os.ModeSticky},
+        {desc: "warning", policy: WarnInsecure, fileMode: 0666, dirMode: 0755, wantWarn: "warning:"},
+        {desc: "ignored", policy: IgnoreInsecure, fileMode: 0666, dirMode: 0777},
+    }
+    for _, test := range tests {
+        top := filepath.Join(t.TempDir(), "top")
+        dir := filepath.Join(top, "dir")
+        path := filepath.Join(dir, "rc")
+        if err := os.MkdirAll(dir, 0700); err != nil {
+            t.Fatal(err)
+        }
+        if err := ioutil.WriteFile(path, []byte("port=9090\n"), 0600); err != nil {
+            t.Fatal(err)
+        }
+        if err := os.Chmod(path, test.fileMode); err != nil {
+            t.Fatal(err)
+        }
+        if err := os.Chmod(top, test.dirMode); err != nil {
+            t.Fatal(err)
+        }
+
+        var out bytes.Buffer
+        c := NewConfigoSet("test", 0, path)
+        c.SetOutput(&out)
+        c.SetArguments([]string{})
+        c.SetPermissionPolicy(test.policy)
+        port := c.IntConfig("port", 80, "the port")
+        err := c.Parse()
+        switch {
+        case test.wantErr == "" && err != nil:
+            t.Errorf("%s: Parse: %v", test.desc, err)
+        case test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)):
+            t.Errorf("%s: Parse error %v, want %q", test.desc, err, test.wantErr)
+        case err == nil && *port != 9090:
+            t.Errorf("%s: port %d, want 9090", test.desc, *port)
+        }
+        if !strings.Contains(out.String(), test.wantWarn) || test.wantWarn == "" && out.Len() > 0 {
+            t.Errorf("%s: output %q, want %q", test.desc, out.String(), test.wantWarn)
+        }
+    }
+}
@@ -0,0 +1,161 @@
+package configo
+
+import (
+    "flag"
+    "fmt"
+    "strings"
+)
+
+// Argument is a named positional argument declared with PositionalVar,
+// Positional or Variadic.
+type Argument struct {
+    Name     string
+    Usage    string
+    Value    flag.Value // nil for a variadic argument
+    Optional bool
+    Variadic bool
+    Values   []string // the command line arguments it was given
+
+    minimum int // the fewest values a variadic argument takes
+}
+
+// PositionalVar declares the next positional argument with the specified
+// name and usage string.  Its type and value are represented by value, as
+// with Var.  A required argument must be given on the command line, and all
+// required arguments must be declared before the optional ones.
+//
+// Once positional arguments are declared, Parse checks that the command line
+// has every required one and no more than were declared.
+func (c *ConfigoSet) PositionalVar(value flag.Value, name string, usage string, optional bool) {
+    c.addArgument(&Argument{Name: name, Usage: usage, Value: value, Optional: optional})
+}
+
+// PositionalVar declares the next positional argument with the specified
+// name and usage string.
+func PositionalVar(value flag.Value, name string, usage string, optional bool) {
+    configuration.PositionalVar(value, name, usage, optional)
+}
+
+// Positional declares the next positional argument, a required string with
+// the specified name and usage string.  The return value is the address of a
+// string variable that stores the value of the argument.
+func (c *ConfigoSet) Positional(name string, usage string) *string {
+    p := new(string)
+    c.PositionalVar(newStringValue("", p), name, usage, false)
+    return p
+}
+
+// Positional declares the next positional argument, a required string with
+// the specified name and usage string.
+func Positional(name string, usage string) *string {
+    return configuration.Positional(name, usage)
+}
+
+// Variadic declares the last positional argument, which takes all of the
+// remaining arguments and requires at least minimum of them.  The return
+// value is the address of a string slice that stores them.
+func (c *ConfigoSet) Variadic(name string, usage string, minimum int) *[]string {
+    arg := &Argument{Name: name, Usage: usage, Optional: minimum == 0, Variadic: true, minimum: minimum}
+    c.addArgument(arg)
+    return &arg.Values
+}
+
+// Variadic declares the last positional argument, which takes all of the
+// remaining arguments and requires at least minimum of them.
+func Variadic(name string, usage string, minimum int) *[]string {
+    return configuration.Variadic(name, usage, minimum)
+}
+
+// LookupArg returns the Argument structure of the named positional argument,
+// returning nil if none exists.
+func (c *ConfigoSet) LookupArg(name string) *Argument {
+    for _, arg := range c.positionals {
+        if arg.Name == name {
+            return arg
+        }
+    }
+    return nil
+}
+
+// LookupArg returns the Argument structure of the named positional argument,
+// returning nil if none exists.
+func LookupArg(name string) *Argument {
+    return configuration.LookupArg(name)
+}
+
+func (c *ConfigoSet) addArgument(arg *Argument) {
+    if c.LookupArg(arg.Name) != nil {
+        msg := fmt.Sprintf("%s argument redefined: %s", c.name, arg.Name)
+        fmt.Fprintln(c.out(), msg)
+        panic(msg)
+    }
+    if n := len(c.positionals); n > 0 {
+        last := c.positionals[n-1]
+        if last.Variadic {
+            panic(fmt.Sprintf("%s argument %s declared after variadic %s", c.name, arg.Name, last.Name))
+        }
+        if last.Optional && !arg.Optional {
+            panic(fmt.Sprintf("%s required argument %s declared after optional %s", c.name, arg.Name, last.Name))
+        }
+    }
+    c.positionals = append(c.positionals, arg)
+}
+
+// parseArgs sets the declared positional arguments from the remaining
+// command line arguments.  Without declarations any arguments are accepted,
+// and when the first argument names a subcommand the rest are left to it.
+func (c *ConfigoSet) parseArgs() error {
+    if len(c.positionals) == 0 {
+        return nil
+    }
+    if len(c.args) > 0 && c.commands[c.args[0]] != nil {
+        return nil
+    }
+
+    args := c.args
+    for _, arg := range c.positionals {
+        arg.Values = nil
+        if arg.Variadic {
+            if len(args) < arg.minimum {
+                return c.failf(fmt.Errorf("%s: %s needs at least %d arguments", c.name, arg.Name, arg.minimum))
+            }
+            arg.Values = append(arg.Values, args...)
+            args = nil
+            break
+        }
+        if len(args) == 0 {
+            if arg.Optional {
+                continue
+            }
+            return c.failf(fmt.Errorf("%s: missing argument %s", c.name, arg.Name))
+        }
+
+        if err := arg.Value.Set(args[0]); err != nil {
+            return c.failf(fmt.Errorf("invalid value %q for argument %s: %v", args[0], arg.Name, err))
+        }
+        arg.Values = args[:1]
+        args = args[1:]
+    }
+
+    if len(args) > 0 {
+        return c.failf(fmt.Errorf("%s: unexpected argument %q", c.name, args[0]))
+    }
+    return nil
+}
+
+// argumentsSynopsis returns the positional arguments for a usage line, such
+// as "src [dst] [files...]", or "" if none are declared.
+func (c *ConfigoSet) argumentsSynopsis() string {
+    var names []string
+    for _, arg := range c.positionals {
+        name := arg.Name
+        if arg.Variadic {
+            name += "..."
+        }
+        if arg.Optional {
+            name = "[" + name + "]"
+        }
+        names = append(names, name)
+    }
+    return strings.Join(names, " ")
+}
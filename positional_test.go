@@ -0,0 +1,57 @@
+package configo
+
+import (
+    "io/ioutil"
+    "path/filepath"
+    "reflect"
+    "testing"
+)
+
+func TestPositionalArguments(t *testing.T) {
+    tests := []struct {
+        args    []string
+        src     string
+        files   []string
+        wantErr bool
+    }{
+        {args: []string{"a"}, src: "a"},
+        {args: []string{"a", "b", "c"}, src: "a", files: []string{"b", "c"}},
+        {args: []string{}, wantErr: true},
+    }
+    for _, test := range tests {
+        c := NewConfigoSet("test", 0, "")
+        c.SetOutput(ioutil.Discard)
+        src := c.Positional("src", "the source")
+        files := c.Variadic("files", "the files", 0)
+        c.SetArguments(test.args)
+        err := c.parseFlags()
+        if (err != nil) != test.wantErr {
+            t.Errorf("%q: error %v, want error %v", test.args, err, test.wantErr)
+            continue
+        }
+        if err == nil && (*src != test.src || !reflect.DeepEqual(*files, test.files)) {
+            t.Errorf("%q: src %q files %q, want %q %q", test.args, *src, *files, test.src, test.files)
+        }
+    }
+}
+
+func TestPositionalArgumentsWithSubcommands(t *testing.T) {
+    root := NewCommand("prog", 0, filepath.Join(t.TempDir(), "rc"))
+    root.SetCreatePolicy(NeverCreate)
+    root.SetOutput(ioutil.Discard)
+    root.Positional("target", "the target")
+    var got []string
+    root.AddCommand("serve", "serve it", func(cmd *Command, args []string) error {
+        got = args
+        return nil
+    })
+
+    root.SetArguments([]string{"serve", "x"})
+    if err := root.Execute(); err != nil {
+        t.Fatalf("Execute: %v", err)
+    }
+    if !reflect.DeepEqual(got, []string{"x"}) {
+        t.Errorf("serve ran with %q, want [x]", got)
+    }
+}
+
@@ -0,0 +1,167 @@
+package configo
+
+import (
+    "fmt"
+    "os"
+    "sort"
+    "strings"
+)
+
+// The name of the command line flag added by EnableProfiles, and of the
+// configuration file sections holding profiles.
+const (
+    profileFlagName = "profile"
+    profileSection  = "profile"
+)
+
+// EnableProfiles adds the command line flag -profile, which selects a profile
+// of the configuration file.  A profile is a section such as
+//
+//	[profile staging]
+//	port=8081
+//
+// in the key/value format, or the table [profile.staging] in TOML and the
+// mapping profile: staging: in YAML.  The values of the selected profile
+// overlay those set outside any profile, wherever they are in the file, and
+// the other profiles are ignored, although their items must exist.  The
+// profile also overrides the blocks of a [match] section which apply.  The
+// name of a profile may have dots, as in [profile prod.eu].  Without -profile
+// the profile is taken from the environment variable PROG_PROFILE, where
+// PROG is the name of the set in upper case.  When a configuration file is
+// read, the selected profile must be in it or in the embedded defaults.
+func (c *ConfigoSet) EnableProfiles() {
+    c.profileFlags = true
+    c.StringFlagVar(&c.profile, profileFlagName, "", "the profile of the configuration file to use")
+}
+
+// EnableProfiles adds the command line flag -profile, which selects a profile
+// of the configuration file.
+func EnableProfiles() {
+    configuration.EnableProfiles()
+}
+
+// Profile returns the name of the selected profile, or "" if there is none.
+func (c *ConfigoSet) Profile() string {
+    return c.profile
+}
+
+// Profile returns the name of the selected profile, or "" if there is none.
+func Profile() string {
+    return configuration.Profile()
+}
+
+// Profiles returns the names of the profiles in the configuration read by
+// Parse, in order.
+func (c *ConfigoSet) Profiles() []string {
+    names := make([]string, 0, len(c.profiles))
+    for name := range c.profiles {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+// Profiles returns the names of the profiles in the configuration read by
+// Parse, in order.
+func Profiles() []string {
+    return configuration.Profiles()
+}
+
+// profileEnvVar returns the name of the environment variable selecting the
+// profile, such as "MYPROG_PROFILE".
+func (c *ConfigoSet) profileEnvVar() string {
+    return strings.Map(func(r rune) rune {
+        if r >= 'a' && r <= 'z' {
+            return r - 'a' + 'A'
+        }
+        if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+            return r
+        }
+        return '_'
+    }, c.name) + "_PROFILE"
+}
+
+// selectProfile selects the profile from the environment if the -profile
+// flag was not given.
+func (c *ConfigoSet) selectProfile() {
+    if !c.profileFlags || c.Lookup(profileFlagName).source == sourceFlag {
+        return
+    }
+    c.profile = os.Getenv(c.profileEnvVar())
+}
+
+// checkProfile returns an error if the selected profile is not in the
+// configuration file called name, or the embedded defaults read before it.
+func (c *ConfigoSet) checkProfile(name string) error {
+    if c.profile == "" || c.profiles[c.profile] {
+        return nil
+    }
+    return fmt.Errorf("unknown profile %s in %s", c.profile, name)
+}
+
+// checkProfiles returns an error if the entries of a profile which is not
+// selected name an unknown item, as they would if it were selected.
+func (c *ConfigoSet) checkProfiles(profiles map[string][]Entry, name string) error {
+    names := make([]string, 0, len(profiles))
+    for profile := range profiles {
+        names = append(names, profile)
+    }
+    sort.Strings(names)
+    for _, profile := range names {
+        if profile == c.profile {
+            continue
+        }
+        for _, e := range profiles[profile] {
+            if !c.isEntryName(e.Name) {
+                return fmt.Errorf("unknown configuration item %s in profile %s in %s on line %d", e.Name, profile, name, e.Line)
+            }
+        }
+    }
+    return nil
+}
+
+// splitProfiles splits entries into those outside any profile and those of
+// each profile, whose names are made relative to the profile.  An entry
+// which names an item, such as "profile.x" for an item of that name, is never
+// taken to be in a profile.  As profile names may have dots, as in "[profile
+// prod.eu]", the name is taken to end at the first dot which leaves the name
+// of an item.
+func (c *ConfigoSet) splitProfiles(entries []Entry) ([]Entry, map[string][]Entry) {
+    var base []Entry
+    profiles := make(map[string][]Entry)
+    for _, e := range entries {
+        rest := ""
+        if _, config, _ := c.lookupEntry(e.Name); config == nil {
+            for _, prefix := range []string{profileSection + " ", profileSection + "."} {
+                if strings.HasPrefix(e.Name, prefix) {
+                    rest = strings.TrimSpace(e.Name[len(prefix):])
+                }
+            }
+        }
+
+        end := -1
+        for i := 0; i < len(rest); i++ {
+            if rest[i] == '.' {
+                end = i
+                if c.isEntryName(rest[i+1:]) {
+                    break
+                }
+            }
+        }
+        if end <= 0 {
+            base = append(base, e)
+            continue
+        }
+        name := strings.TrimSpace(rest[:end])
+        e.Name = rest[end+1:]
+        profiles[name] = append(profiles[name], e)
+    }
+    return base, profiles
+}
+
+// sectionSource returns the source of a value from a section of the
+// configuration in source, such as "file:/home/gopher/.progrc [profile
+// staging]" for the section "profile staging".
+func sectionSource(source, section string) string {
+    return fmt.Sprintf("%s [%s]", source, section)
+}
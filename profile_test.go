@@ -0,0 +1,64 @@
+package configo
+
+import (
+    "io/ioutil"
+    "path/filepath"
+    "runtime"
+    "strings"
+    "testing"
+)
+
+func TestProfiles(t *testing.T) {
+    tests := []struct {
+        desc    string
+        content string // "" for no configuration file
+        args    []string
+        want    int
+        section string
+        wantErr string
+    }{
+        {desc: "base", content: "port=1\n[profile prod]\nport=2\n", args: []string{}, want: 1},
+        {desc: "selected", content: "port=1\n[profile prod]\nport=2\n", args: []string{"-profile", "prod"}, want: 2, section: " [profile prod]"},
+        {desc: "dotted name", content: "port=1\n[profile prod.eu]\nport=3\n", args: []string{"-profile", "prod.eu"}, want: 3, section: " [profile prod.eu]"},
+        {desc: "over match", content: "[profile prod]\nport=2\n[match os=" + runtime.GOOS + "]\nport=4\n", args: []string{"-profile", "prod"}, want: 2, section: " [profile prod]"},
+        {desc: "unknown profile", content: "port=1\n", args: []string{"-profile", "prod"}, wantErr: "unknown profile prod"},
+        {desc: "unknown item in other profile", content: "port=1\n[profile dev]\nprot=2\n", args: []string{}, wantErr: "unknown configuration item prot in profile dev"},
+        {desc: "no config", content: "port=1\n", args: []string{"-no-config", "-profile", "prod"}, want: 80},
+        {desc: "missing file", args: []string{"-profile", "prod"}, want: 80},
+    }
+    for _, test := range tests {
+        path := filepath.Join(t.TempDir(), "rc")
+        if test.content != "" {
+            if err := ioutil.WriteFile(path, []byte(test.content), 0600); err != nil {
+                t.Fatal(err)
+            }
+        }
+        c := NewConfigoSet("test", 0, path)
+        c.SetOutput(ioutil.Discard)
+        c.SetCreatePolicy(NeverCreate)
+        c.SetArguments(test.args)
+        c.EnableConfigFlags()
+        c.EnableProfiles()
+        port := c.IntConfig("port", 80, "the port")
+
+        err := c.Parse()
+        if test.wantErr != "" {
+            if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+                t.Errorf("%s: Parse error %v, want %q", test.desc, err, test.wantErr)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("%s: Parse: %v", test.desc, err)
+            continue
+        }
+        if *port != test.want {
+            t.Errorf("%s: port %d, want %d", test.desc, *port, test.want)
+        }
+        if test.section != "" {
+            if source := c.Lookup("port").Source(); source != "file:"+path+test.section {
+                t.Errorf("%s: source %q, want section %q", test.desc, source, test.section)
+            }
+        }
+    }
+}
@@ -0,0 +1,243 @@
+package configo
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "math"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// jsonSchemaDialect is the JSON Schema version written by WriteJSONSchema.
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// WriteJSONSchema writes a JSON Schema describing the configuration file to
+// w, so that editors and linters can validate and complete YAML, TOML or JSON
+// configuration files.  Every item which may be set in the configuration
+// file is a property with its type, default value and usage text as
+// description, and the choices set with SetChoices as examples, since Parse
+// accepts other values too.  Dotted names, such as "gopher.type", and the sections of
+// subcommands are nested objects.  Unknown properties are not allowed, as
+// Parse rejects them.  Deprecated items and old names added with Alias are
+// marked deprecated.  Secrets may also be given by their name_file and
+// name_command entries.  The "profile" and "match" objects hold the
+// sections of profiles and match sections by name, each with the items of
+// the file.  It is an error for an item's name to be the section of
+// another, such as "a" and "a.b", as no schema allows both.
+//
+// Items have no minimum, maximum or required marker in this package, so
+// none are written, except that unsigned integers have a minimum of zero.
+func (c *ConfigoSet) WriteJSONSchema(w io.Writer) error {
+    root := jsonSchemaObject()
+    root["$schema"] = jsonSchemaDialect
+    root["title"] = c.name
+
+    if err := c.jsonSchemaProperties(root, ""); err != nil {
+        return err
+    }
+
+    // Every profile and match section holds the same items as the file.
+    items := jsonSchemaObject()
+    properties := root["properties"].(map[string]interface{})
+    for name, property := range properties {
+        items["properties"].(map[string]interface{})[name] = property
+    }
+    for _, section := range []struct{ name, usage string }{
+        {profileSection, "the profiles selected with -profile, by name"},
+        {matchSection, "the items set on machines matching the condition, such as \"os=linux\""},
+    } {
+        if _, ok := properties[section.name]; ok {
+            continue
+        }
+        properties[section.name] = map[string]interface{}{
+            "type":                 "object",
+            "description":          section.usage,
+            "additionalProperties": items,
+        }
+    }
+
+    encoder := json.NewEncoder(w)
+    encoder.SetIndent("", "  ")
+    return encoder.Encode(root)
+}
+
+// WriteJSONSchema writes a JSON Schema describing the configuration file to
+// w.
+func WriteJSONSchema(w io.Writer) error {
+    return configuration.WriteJSONSchema(w)
+}
+
+// jsonSchemaProperties adds the items of c which may be set in the
+// configuration file to schema, with names prefixed by prefix, followed by
+// its old names and the items of its subcommands.
+func (c *ConfigoSet) jsonSchemaProperties(schema map[string]interface{}, prefix string) error {
+    var err error
+    c.VisitAll(func(config *Configo) {
+        if !config.IsConfig || err != nil {
+            return
+        }
+        property := jsonSchemaItem(config)
+        if c.isDeprecated(config.Name) {
+            property["deprecated"] = true
+            property["description"] = "deprecated: " + c.deprecated[config.Name]
+        }
+        err = jsonSchemaAdd(schema, prefix+config.Name, property)
+        if err != nil || !isSecret(config) {
+            return
+        }
+        // A secret may instead be read from a file or printed by a command.
+        for _, source := range []struct{ suffix, usage string }{
+            {secretFileSuffix, "file holding " + config.Name},
+            {secretCommandSuffix, "command printing " + config.Name},
+        } {
+            if err = jsonSchemaAdd(schema, prefix+config.Name+source.suffix, map[string]interface{}{
+                "type":        "string",
+                "description": source.usage,
+            }); err != nil {
+                return
+            }
+        }
+    })
+    if err != nil {
+        return err
+    }
+
+    // Old names are still accepted, so they are valid properties too.
+    olds := make([]string, 0, len(c.aliases))
+    for old := range c.aliases {
+        olds = append(olds, old)
+    }
+    sort.Strings(olds)
+    for _, old := range olds {
+        config := c.formal[c.aliases[old]]
+        if !config.IsConfig {
+            continue
+        }
+        property := jsonSchemaItem(config)
+        property["deprecated"] = true
+        property["description"] = "deprecated: " + c.deprecated[old]
+        if err := jsonSchemaAdd(schema, prefix+old, property); err != nil {
+            return err
+        }
+    }
+
+    names := make([]string, 0, len(c.commands))
+    for name := range c.commands {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    for _, name := range names {
+        if err := c.commands[name].jsonSchemaProperties(schema, prefix+name+"."); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// jsonSchemaObject returns the schema of an object with no other properties
+// than those added to it.
+func jsonSchemaObject() map[string]interface{} {
+    return map[string]interface{}{
+        "type":                 "object",
+        "properties":           map[string]interface{}{},
+        "additionalProperties": false,
+    }
+}
+
+// jsonSchemaAdd adds property to schema under the dotted name, nesting an
+// object for each part of the name but the last.  It returns an error if
+// the name, or one of its sections, is already the name of another item.
+func jsonSchemaAdd(schema map[string]interface{}, name string, property map[string]interface{}) error {
+    parts := strings.Split(name, ".")
+    for i, part := range parts[:len(parts)-1] {
+        properties := schema["properties"].(map[string]interface{})
+        child, ok := properties[part].(map[string]interface{})
+        if !ok {
+            child = jsonSchemaObject()
+            properties[part] = child
+        } else if child["properties"] == nil {
+            return fmt.Errorf("configuration item %s conflicts with the section of %s", strings.Join(parts[:i+1], "."), name)
+        }
+        schema = child
+    }
+    properties := schema["properties"].(map[string]interface{})
+    if _, ok := properties[parts[len(parts)-1]]; ok {
+        return fmt.Errorf("configuration item %s conflicts with a section or item of the same name", name)
+    }
+    properties[parts[len(parts)-1]] = property
+    return nil
+}
+
+// jsonSchemaItem returns the schema of the value of config.
+func jsonSchemaItem(config *Configo) map[string]interface{} {
+    property := map[string]interface{}{}
+    if config.Usage != "" {
+        property["description"] = config.Usage
+    }
+
+    // convert returns a value written in the configuration file as JSON.
+    convert := func(value string) interface{} { return value }
+    switch config.Value.(type) {
+    case *boolValue:
+        property["type"] = "boolean"
+        convert = func(value string) interface{} {
+            if b, err := strconv.ParseBool(value); err == nil {
+                return b
+            }
+            return value
+        }
+    case *intValue, *int64Value:
+        property["type"] = "integer"
+        convert = func(value string) interface{} {
+            if i, err := strconv.ParseInt(value, 0, 64); err == nil {
+                return i
+            }
+            return value
+        }
+    case *uintValue, *uint64Value:
+        property["type"] = "integer"
+        property["minimum"] = 0
+        convert = func(value string) interface{} {
+            if u, err := strconv.ParseUint(value, 0, 64); err == nil {
+                return u
+            }
+            return value
+        }
+    case *float64Value:
+        property["type"] = "number"
+        convert = func(value string) interface{} {
+            if f, err := strconv.ParseFloat(value, 64); err == nil {
+                return f
+            }
+            return value
+        }
+    case *stringValue, *durationValue, *secretValue:
+        property["type"] = "string"
+    default:
+        // A user-defined Value may be written in any form, so only its
+        // default is known.
+    }
+    if value := convert(config.DefaultValue); jsonValid(value) {
+        property["default"] = value
+    }
+
+    if len(config.Choices) > 0 {
+        choices := make([]interface{}, 0, len(config.Choices))
+        for _, choice := range config.Choices {
+            if value := convert(choice); jsonValid(value) {
+                choices = append(choices, value)
+            }
+        }
+        property["examples"] = choices
+    }
+    return property
+}
+
+// jsonValid reports whether value can be written as JSON, which has no NaN or
+// infinite numbers.
+func jsonValid(value interface{}) bool {
+    f, ok := value.(float64)
+    return !ok || !math.IsNaN(f) && !math.IsInf(f, 0)
+}
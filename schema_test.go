@@ -0,0 +1,118 @@
+package configo
+
+import (
+    "bytes"
+    "encoding/json"
+    "math"
+    "strings"
+    "testing"
+)
+
+func TestWriteJSONSchema(t *testing.T) {
+    c := NewConfigoSet("prog", 0, "")
+    c.IntConfig("port", 80, "the port")
+    c.StringConfig("db.host", "localhost", "the database host")
+    c.StringConfig("colour", "", "the colour")
+    c.Deprecate("colour", "it is ignored")
+    c.Float64Config("rate", math.NaN(), "the rate")
+    c.Float64Config("limit", math.Inf(1), "the limit")
+    c.Alias("listen_port", "port")
+    c.SecretConfig("password", "the password")
+    c.StringConfig("level", "info", "the log level")
+    c.SetChoices("level", "debug", "info")
+
+    var b bytes.Buffer
+    if err := c.WriteJSONSchema(&b); err != nil {
+        t.Fatal(err)
+    }
+    var schema struct {
+        Properties map[string]struct {
+            Type       string
+            Default    interface{}
+            Deprecated bool
+            Enum       []interface{}
+            Examples   []interface{}
+            Properties map[string]interface{}
+        }
+    }
+    if err := json.Unmarshal(b.Bytes(), &schema); err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        name       string
+        typ        string
+        def        interface{}
+        deprecated bool
+    }{
+        {"port", "integer", float64(80), false},
+        {"listen_port", "integer", float64(80), true},
+        {"colour", "string", "", true},
+        {"rate", "number", nil, false},
+        {"limit", "number", nil, false},
+        {"db", "object", nil, false},
+        {"password", "string", "", false},
+        {"password_file", "string", nil, false},
+        {"password_command", "string", nil, false},
+    }
+    for _, test := range tests {
+        property, ok := schema.Properties[test.name]
+        if !ok {
+            t.Errorf("%s: missing", test.name)
+            continue
+        }
+        if property.Type != test.typ || property.Default != test.def || property.Deprecated != test.deprecated {
+            t.Errorf("%s: type %s default %v deprecated %v, want %s %v %v", test.name,
+                property.Type, property.Default, property.Deprecated, test.typ, test.def, test.deprecated)
+        }
+    }
+    // Choices are not checked by Parse, so they are only examples.
+    if level := schema.Properties["level"]; level.Enum != nil || len(level.Examples) != 2 || level.Examples[0] != "debug" {
+        t.Errorf("level: enum %v examples %v, want the examples debug and info", level.Enum, level.Examples)
+    }
+    if _, ok := schema.Properties["db"].Properties["host"]; !ok {
+        t.Errorf("db.host is not nested: %v", schema.Properties["db"])
+    }
+
+    // Profiles and match sections may set any item, but nothing else.
+    var sections struct {
+        Properties map[string]json.RawMessage
+    }
+    if err := json.Unmarshal(b.Bytes(), &sections); err != nil {
+        t.Fatal(err)
+    }
+    for _, name := range []string{"profile", "match"} {
+        var section struct {
+            Type                 string
+            AdditionalProperties struct {
+                Properties           map[string]interface{}
+                AdditionalProperties bool
+            }
+        }
+        if err := json.Unmarshal(sections.Properties[name], &section); err != nil {
+            t.Errorf("%s: %v", name, err)
+            continue
+        }
+        items := section.AdditionalProperties
+        if section.Type != "object" || items.AdditionalProperties || len(items.Properties) != len(schema.Properties)-2 {
+            t.Errorf("%s: type %s with %d items, additional %v, want an object of %d items", name,
+                section.Type, len(items.Properties), items.AdditionalProperties, len(schema.Properties)-2)
+        }
+        if _, ok := items.Properties["port"]; !ok {
+            t.Errorf("%s: no port item", name)
+        }
+    }
+}
+
+func TestWriteJSONSchemaConflict(t *testing.T) {
+    for _, names := range [][]string{{"a", "a.b"}, {"a.b", "a"}} {
+        c := NewConfigoSet("prog", 0, "")
+        for _, name := range names {
+            c.IntConfig(name, 0, "")
+        }
+        err := c.WriteJSONSchema(new(bytes.Buffer))
+        if err == nil || !strings.Contains(err.Error(), "conflicts") {
+            t.Errorf("%v: error %v, want a conflict", names, err)
+        }
+    }
+}
@@ -0,0 +1,226 @@
+package configo
+
+import (
+    "errors"
+    "fmt"
+    "io/ioutil"
+    "os"
+    "os/exec"
+    "strings"
+)
+
+// redacted is how a secret which has been set is written in all output.
+const redacted = "****"
+
+// -- secret Value
+//
+// A secret is a string which is never written out: String returns "****"
+// once it is set.  A value starting with "@" is the path of a file holding
+// the secret, such as "@/run/secrets/db_password"; a trailing newline in the
+// file is dropped.  Write "@@" for a secret which really starts with "@".
+type secretValue string
+
+func newSecretValue(p *string) *secretValue {
+    *p = ""
+    return (*secretValue)(p)
+}
+
+func (s *secretValue) Set(val string) error {
+    if strings.HasPrefix(val, "@@") {
+        val = val[1:]
+    } else if strings.HasPrefix(val, "@") {
+        content, err := ioutil.ReadFile(val[1:])
+        if err != nil {
+            return err
+        }
+        val = strings.TrimSuffix(strings.TrimSuffix(string(content), "\n"), "\r")
+    }
+    *s = secretValue(val)
+    return nil
+}
+
+func (s *secretValue) String() string {
+    if *s == "" {
+        return ""
+    }
+    return redacted
+}
+
+// isSecret reports whether config holds a secret.
+func isSecret(config *Configo) bool {
+    _, ok := config.Value.(*secretValue)
+    return ok
+}
+
+// The suffixes of configuration file entries which name the file holding a
+// secret, such as "password_file" for "password", or a command which prints
+// it, such as "password_command".
+const (
+    secretFileSuffix    = "_file"
+    secretCommandSuffix = "_command"
+)
+
+// lookupSecretSource returns the secret item set by an entry naming a file or
+// command, the set it belongs to and its name in that set, or a nil item if
+// the entry names no secret.
+func (c *ConfigoSet) lookupSecretSource(name string) (*ConfigoSet, *Configo, string) {
+    for _, suffix := range []string{secretFileSuffix, secretCommandSuffix} {
+        if strings.HasSuffix(name, suffix) {
+            set, config, local := c.lookupEntry(strings.TrimSuffix(name, suffix))
+            if config != nil && isSecret(config) {
+                return set, config, local
+            }
+        }
+    }
+    return nil, nil, name
+}
+
+// readSecretSource returns the value of the secret set by an entry naming a
+// file or command.  The command is run without a shell; its output, less a
+// trailing newline, is the secret.
+func readSecretSource(e Entry) (string, error) {
+    if strings.HasSuffix(e.Name, secretFileSuffix) {
+        return "@" + e.Value, nil
+    }
+    args := strings.Fields(e.Value)
+    if len(args) == 0 {
+        return "", errors.New("empty command")
+    }
+    out, err := exec.Command(args[0], args[1:]...).Output()
+    if err != nil {
+        return "", fmt.Errorf("command failed: %v", err)
+    }
+    value := strings.TrimSuffix(strings.TrimSuffix(string(out), "\n"), "\r")
+    if strings.HasPrefix(value, "@") {
+        value = "@" + value
+    }
+    return value, nil
+}
+
+// warnSecretFile warns when the configuration file at path, which set
+// secrets, can be read by other users.
+func (c *ConfigoSet) warnSecretFile(path string) {
+    info, err := os.Stat(path)
+    if err != nil || info.Mode().Perm()&0077 == 0 {
+        return
+    }
+    for _, config := range c.fileConfigs(false) {
+        if isSecret(config) && (config.source == sourceFile+path || strings.HasPrefix(config.source, sourceFile+path+" [")) {
+            fmt.Fprintf(c.out(), "warning: %s holds secrets but its mode %04o lets other users read it\n", path, info.Mode().Perm())
+            return
+        }
+    }
+}
+
+// -- User functions for registering secrets
+
+// SecretVar defines a secret config item with specified name and usage
+// string.  The argument p points to a string variable in which to store the
+// secret.  Secrets have no default value and are written as "****" in usage
+// output, default configuration files and everywhere else.  In the
+// configuration file the secret may instead be read from a file named by
+// the item name_file, or printed by a command given by name_command, as in
+//
+//	password_file=/run/secrets/db_password
+//	password_command=pass show db
+//
+// and anywhere a value of "@path" reads the secret from path.
+//
+// This item can be specified on the command line and in the configuration
+// file.
+func (c *ConfigoSet) SecretVar(p *string, name string, usage string) {
+    isFlag := true
+    isConfig := true
+    c.Var(newSecretValue(p), name, usage, isFlag, isConfig)
+}
+
+// SecretFlagVar defines a secret command line flag with specified name and
+// usage string.  The argument p points to a string variable in which to store
+// the secret.
+//
+// This item can only be specified on the command line.
+func (c *ConfigoSet) SecretFlagVar(p *string, name string, usage string) {
+    isFlag := true
+    isConfig := false
+    c.Var(newSecretValue(p), name, usage, isFlag, isConfig)
+}
+
+// SecretConfigVar defines a secret config item with specified name and usage
+// string.  The argument p points to a string variable in which to store the
+// secret.
+//
+// This item can only be specified in the configuration file.
+func (c *ConfigoSet) SecretConfigVar(p *string, name string, usage string) {
+    isFlag := false
+    isConfig := true
+    c.Var(newSecretValue(p), name, usage, isFlag, isConfig)
+}
+
+// SecretVar defines a secret config item with specified name and usage
+// string.  The argument p points to a string variable in which to store the
+// secret.
+func SecretVar(p *string, name string, usage string) {
+    configuration.SecretVar(p, name, usage)
+}
+
+// SecretFlagVar defines a secret command line flag with specified name and
+// usage string.  The argument p points to a string variable in which to store
+// the secret.
+func SecretFlagVar(p *string, name string, usage string) {
+    configuration.SecretFlagVar(p, name, usage)
+}
+
+// SecretConfigVar defines a secret config item with specified name and usage
+// string.  The argument p points to a string variable in which to store the
+// secret.
+func SecretConfigVar(p *string, name string, usage string) {
+    configuration.SecretConfigVar(p, name, usage)
+}
+
+// Secret defines a secret config item with specified name and usage string.
+// The return value is the address of a string variable that stores the
+// secret.
+func (c *ConfigoSet) Secret(name string, usage string) *string {
+    p := new(string)
+    c.SecretVar(p, name, usage)
+    return p
+}
+
+// SecretFlag defines a secret command line flag with specified name and usage
+// string.  The return value is the address of a string variable that stores
+// the secret.
+func (c *ConfigoSet) SecretFlag(name string, usage string) *string {
+    p := new(string)
+    c.SecretFlagVar(p, name, usage)
+    return p
+}
+
+// SecretConfig defines a secret config item with specified name and usage
+// string.  The return value is the address of a string variable that stores
+// the secret.
+func (c *ConfigoSet) SecretConfig(name string, usage string) *string {
+    p := new(string)
+    c.SecretConfigVar(p, name, usage)
+    return p
+}
+
+// Secret defines a secret config item with specified name and usage string.
+// The return value is the address of a string variable that stores the
+// secret.
+func Secret(name string, usage string) *string {
+    return configuration.Secret(name, usage)
+}
+
+// SecretFlag defines a secret command line flag with specified name and usage
+// string.  The return value is the address of a string variable that stores
+// the secret.
+func SecretFlag(name string, usage string) *string {
+    return configuration.SecretFlag(name, usage)
+}
+
+// SecretConfig defines a secret config item with specified name and usage
+// string.  The return value is the address of a string variable that stores
+// the secret.
+func SecretConfig(name string, usage string) *string {
+    return configuration.SecretConfig(name, usage)
+}
@@ -0,0 +1,155 @@
+package configo
+
+import (
+    "bytes"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func TestSecretCommand(t *testing.T) {
+    tests := []struct {
+        args    []string
+        want    string
+        command bool
+    }{
+        {args: []string{}, want: "hunter2", command: true},
+        {args: []string{"-password=x"}, want: "x", command: false},
+    }
+    for _, test := range tests {
+        dir := t.TempDir()
+        marker := filepath.Join(dir, "ran")
+        command := filepath.Join(dir, "command")
+        script := "#!/bin/sh\ntouch " + marker + "\necho hunter2\n"
+        if err := ioutil.WriteFile(command, []byte(script), 0700); err != nil {
+            t.Fatal(err)
+        }
+        path := filepath.Join(dir, "rc")
+        if err := ioutil.WriteFile(path, []byte("password_command="+command+"\n"), 0600); err != nil {
+            t.Fatal(err)
+        }
+
+        c := NewConfigoSet("test", 0, path)
+        c.SetOutput(ioutil.Discard)
+        password := c.Secret("password", "the password")
+        c.SetArguments(test.args)
+        if err := c.Parse(); err != nil {
+            t.Fatalf("%q: Parse: %v", test.args, err)
+        }
+        if *password != test.want {
+            t.Errorf("%q: password %q, want %q", test.args, *password, test.want)
+        }
+        if _, err := os.Stat(marker); (err == nil) != test.command {
+            t.Errorf("%q: command ran %v, want %v", test.args, err == nil, test.command)
+        }
+    }
+}
+
+func TestSecretRedacted(t *testing.T) {
+    c := NewConfigoSet("test", 0, filepath.Join(t.TempDir(), "rc"))
+    var out bytes.Buffer
+    c.SetOutput(&out)
+    password := c.Secret("password", "the password")
+    c.SetArguments([]string{"-password=hunter2"})
+    if err := c.Parse(); err != nil {
+        t.Fatalf("Parse: %v", err)
+    }
+    if *password != "hunter2" {
+        t.Errorf("password %q, want hunter2", *password)
+    }
+
+    c.Visit(func(config *Configo) {
+        if value := config.Value.String(); value != redacted {
+            t.Errorf("Visit: %s is %q, want %q", config.Name, value, redacted)
+        }
+    })
+    c.PrintDefaults()
+    c.usage()
+    if err := c.WriteDefaultConfigTo(&out); err != nil {
+        t.Fatalf("WriteDefaultConfigTo: %v", err)
+    }
+    if strings.Contains(out.String(), "hunter2") {
+        t.Errorf("secret written out:\n%s", out.String())
+    }
+}
+
+func TestSecretFile(t *testing.T) {
+    dir := t.TempDir()
+    secret := filepath.Join(dir, "secret")
+    if err := ioutil.WriteFile(secret, []byte("hunter2\n"), 0600); err != nil {
+        t.Fatal(err)
+    }
+    tests := []struct {
+        content string
+        args    []string
+        want    string
+    }{
+        {content: "password_file=" + secret + "\n", args: []string{}, want: "hunter2"},
+        {content: "password=@" + secret + "\n", args: []string{}, want: "hunter2"},
+        {content: "password=@@hunter2\n", args: []string{}, want: "@hunter2"},
+        {content: "", args: []string{"-password=@" + secret}, want: "hunter2"},
+        {content: "", args: []string{"-password=@@" + secret}, want: "@" + secret},
+    }
+    for _, test := range tests {
+        path := filepath.Join(dir, "rc")
+        if err := ioutil.WriteFile(path, []byte(test.content), 0600); err != nil {
+            t.Fatal(err)
+        }
+        c := NewConfigoSet("test", 0, path)
+        c.SetOutput(ioutil.Discard)
+        password := c.Secret("password", "the password")
+        c.SetArguments(test.args)
+        if err := c.Parse(); err != nil {
+            t.Errorf("%q %q: Parse: %v", test.content, test.args, err)
+            continue
+        }
+        if *password != test.want {
+            t.Errorf("%q %q: password %q, want %q", test.content, test.args, *password, test.want)
+        }
+    }
+
+    c := NewConfigoSet("test", 0, "")
+    c.SetOutput(ioutil.Discard)
+    c.Secret("password", "the password")
+    c.SetArguments([]string{"-password=@" + filepath.Join(dir, "missing")})
+    if err := c.Parse(); err == nil {
+        t.Errorf("Parse with a missing secret file succeeded")
+    }
+}
+
+func TestWarnSecretFile(t *testing.T) {
+    tests := []struct {
+        mode    os.FileMode
+        content string
+        warn    bool
+    }{
+        {mode: 0600, content: "password=hunter2\n", warn: false},
+        {mode: 0640, content: "password=hunter2\n", warn: true},
+        {mode: 0644, content: "password=hunter2\n", warn: true},
+        {mode: 0644, content: "user=gopher\n", warn: false},
+    }
+    for _, test := range tests {
+        path := filepath.Join(t.TempDir(), "rc")
+        if err := ioutil.WriteFile(path, []byte(test.content), test.mode); err != nil {
+            t.Fatal(err)
+        }
+        if err := os.Chmod(path, test.mode); err != nil {
+            t.Fatal(err)
+        }
+        c := NewConfigoSet("test", 0, path)
+        var out bytes.Buffer
+        c.SetOutput(&out)
+        c.Secret("password", "the password")
+        c.String("user", "", "the user")
+        c.SetArguments([]string{})
+        if err := c.Parse(); err != nil {
+            t.Fatalf("%04o %q: Parse: %v", test.mode, test.content, err)
+        }
+        warned := strings.Contains(out.String(), "holds secrets")
+        if warned != test.warn {
+            t.Errorf("%04o %q: warned %v, want %v: %q", test.mode, test.content, warned, test.warn, out.String())
+        }
+    }
+}
@@ -0,0 +1,56 @@
+package configo
+
+import (
+    "fmt"
+)
+
+// Shorthand adds a short name, such as "g" for "gopher_type", for a flag.
+// The short name is only accepted on the command line; it is not valid in a
+// configuration file.  Shorthand panics if the named item is not a flag or
+// short is already in use.
+func (c *ConfigoSet) Shorthand(name, short string) {
+    config, ok := c.formal[name]
+    if !ok || !config.IsFlag {
+        panic(fmt.Sprintf("%s shorthand %s for unknown flag %s", c.name, short, name))
+    }
+    if _, ok := c.shorthands[short]; ok || c.Lookup(short) != nil {
+        msg := fmt.Sprintf("%s flag redefined: %s", c.name, short)
+        fmt.Fprintln(c.out(), msg)
+        panic(msg)
+    }
+
+    if c.shorthands == nil {
+        c.shorthands = make(map[string]string)
+    }
+    c.shorthands[short] = name
+    config.Shorthand = short
+    c.flags.Var(config.Value, short, config.Usage)
+}
+
+// Shorthand adds a short name, such as "g" for "gopher_type", for a flag.
+// The short name is only accepted on the command line.
+func Shorthand(name, short string) {
+    configuration.Shorthand(name, short)
+}
+
+// lookupFlag returns the item set by the named command line flag, which may
+// be a shorthand, returning nil if none exists.
+func (c *ConfigoSet) lookupFlag(name string) *Configo {
+    if name, ok := c.shorthands[name]; ok {
+        return c.formal[name]
+    }
+    return c.Lookup(name)
+}
+
+// flagNames returns the command line names of config for usage output, such
+// as "-g, -gopher_type", or "-g, --gopher_type" with GNUSyntax.
+func (c *ConfigoSet) flagNames(config *Configo) string {
+    long := "-"
+    if c.syntax == GNUSyntax && len(config.Name) > 1 {
+        long = "--"
+    }
+    if config.Shorthand == "" {
+        return long + config.Name
+    }
+    return "-" + config.Shorthand + ", " + long + config.Name
+}
@@ -0,0 +1,83 @@
+package configo
+
+import (
+    "bytes"
+    "io/ioutil"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func newShorthandSet(path string) (*ConfigoSet, *string) {
+    c := NewConfigoSet("test", 0, path)
+    c.SetOutput(ioutil.Discard)
+    c.SetCreatePolicy(NeverCreate)
+    gopherType := c.String("gopher_type", "pocket", "the kind of gopher")
+    c.Shorthand("gopher_type", "g")
+    return c, gopherType
+}
+
+func TestShorthand(t *testing.T) {
+    c, gopherType := newShorthandSet(filepath.Join(t.TempDir(), "rc"))
+    c.SetArguments([]string{"-g", "tree"})
+    if err := c.Parse(); err != nil {
+        t.Fatalf("Parse: %v", err)
+    }
+    if *gopherType != "tree" || c.Lookup("gopher_type").Source() != "flag" {
+        t.Errorf("gopher_type %q from %q, want tree from the command line", *gopherType, c.Lookup("gopher_type").Source())
+    }
+
+    var names []string
+    c.VisitAll(func(config *Configo) { names = append(names, config.Name) })
+    if len(names) != 1 || names[0] != "gopher_type" {
+        t.Errorf("VisitAll visited %q, want only gopher_type", names)
+    }
+    if c.Lookup("g") != nil {
+        t.Errorf("Lookup(g) = %v, want nil", c.Lookup("g"))
+    }
+
+    var usage bytes.Buffer
+    if err := c.WriteUsage(&usage); err != nil {
+        t.Fatal(err)
+    }
+    if strings.Count(usage.String(), "  -g, -gopher_type string\n") != 1 || strings.Contains(usage.String(), "  -g string") {
+        t.Errorf("usage does not list -g with -gopher_type once:\n%s", usage.String())
+    }
+}
+
+func TestShorthandInConfigFile(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "rc")
+    if err := ioutil.WriteFile(path, []byte("g=tree\n"), 0600); err != nil {
+        t.Fatal(err)
+    }
+    c, _ := newShorthandSet(path)
+    c.SetArguments([]string{})
+    if err := c.Parse(); err == nil || !strings.Contains(err.Error(), "unknown configuration item g") {
+        t.Errorf("Parse of a shorthand in the file: %v, want an unknown item", err)
+    }
+}
+
+func TestShorthandPanics(t *testing.T) {
+    tests := []struct {
+        name, short string
+    }{
+        {"gopher_type", "g"},       // the shorthand is taken
+        {"verbose", "g"},           // by another flag
+        {"verbose", "gopher_type"}, // the name of a flag
+        {"nosuch", "n"},            // no such flag
+        {"workers", "w"},           // not a flag
+    }
+    for _, test := range tests {
+        c, _ := newShorthandSet("")
+        c.Bool("verbose", false, "print more")
+        c.IntConfig("workers", 4, "the number of workers")
+        func() {
+            defer func() {
+                if recover() == nil {
+                    t.Errorf("Shorthand(%q, %q) did not panic", test.name, test.short)
+                }
+            }()
+            c.Shorthand(test.name, test.short)
+        }()
+    }
+}
@@ -0,0 +1,204 @@
+package configo
+
+import (
+    "errors"
+    "flag"
+    "fmt"
+    "os"
+    "strings"
+)
+
+// Syntax selects how the command line is parsed.
+type Syntax int
+
+const (
+    // GoSyntax parses the command line with the flag package.  Flags take
+    // one or two dashes and parsing stops at the first non-flag argument.
+    GoSyntax Syntax = iota
+
+    // GNUSyntax parses the command line in the POSIX/GNU style.  Long names
+    // take two dashes, as in --name=value or --name value, and single letter
+    // names and shorthands take one, as in -n value or -nvalue.  Boolean short
+    // flags can be bundled, as in -abc, and boolean flags can be turned off
+    // with --no-name.  Positional arguments may be mixed with flags, and "--"
+    // ends the flags.
+    GNUSyntax
+)
+
+// SetSyntax sets the syntax used to parse the command line.  The default is
+// GoSyntax.
+func (c *ConfigoSet) SetSyntax(syntax Syntax) {
+    c.syntax = syntax
+}
+
+// SetSyntax sets the syntax used to parse the command line.
+func SetSyntax(syntax Syntax) {
+    configuration.SetSyntax(syntax)
+}
+
+// parseGNU parses arguments with GNUSyntax, setting the flags and keeping the
+// positional arguments in c.args.  A set with subcommands stops at the first
+// positional argument, which names the subcommand.
+func (c *ConfigoSet) parseGNU(arguments []string) (err error) {
+    c.args = nil
+    c.nflag = 0
+    for len(arguments) > 0 {
+        arg := arguments[0]
+        arguments = arguments[1:]
+
+        switch {
+        case arg == "--":
+            c.args = append(c.args, arguments...)
+            return nil
+        case strings.HasPrefix(arg, "--"):
+            arguments, err = c.parseLong(arg[2:], arguments)
+        case len(arg) > 1 && arg[0] == '-':
+            arguments, err = c.parseShort(arg[1:], arguments)
+        case len(c.commands) > 0:
+            c.args = append(c.args, arg)
+            c.args = append(c.args, arguments...)
+            return nil
+        default:
+            c.args = append(c.args, arg)
+        }
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// parseLong parses the long flag --arg, taking its value from the rest of
+// the arguments if needed, and returns the remaining arguments.
+func (c *ConfigoSet) parseLong(arg string, arguments []string) ([]string, error) {
+    name, value, hasValue := strings.Cut(arg, "=")
+
+    config := c.lookupCommandLine(name)
+    if config == nil && !hasValue && strings.HasPrefix(name, "no-") {
+        // --no-name turns off a boolean flag.
+        if config = c.lookupCommandLine(name[3:]); config != nil && isBoolFlag(config) {
+            return arguments, c.setFlag(config, "--"+name, name[3:], "false")
+        }
+        config = nil
+    }
+    if config == nil {
+        if name == "help" || name == "h" {
+            return arguments, flag.ErrHelp
+        }
+        return arguments, fmt.Errorf("flag provided but not defined: --%s", name)
+    }
+
+    if !hasValue {
+        if isBoolFlag(config) {
+            value = "true"
+        } else if len(arguments) > 0 {
+            value, arguments = arguments[0], arguments[1:]
+        } else {
+            return arguments, fmt.Errorf("flag needs an argument: --%s", name)
+        }
+    }
+    return arguments, c.setFlag(config, "--"+name, name, value)
+}
+
+// parseShort parses the short flags -arg, taking the value of the last one
+// from the rest of the arguments if needed, and returns the remaining
+// arguments.
+func (c *ConfigoSet) parseShort(arg string, arguments []string) ([]string, error) {
+    for i := 0; i < len(arg); i++ {
+        name := arg[i : i+1]
+        config := c.lookupCommandLine(name)
+        if config == nil {
+            if name == "h" {
+                return arguments, flag.ErrHelp
+            }
+            return arguments, fmt.Errorf("flag provided but not defined: -%s", name)
+        }
+
+        if isBoolFlag(config) {
+            if err := c.setFlag(config, "-"+name, name, "true"); err != nil {
+                return arguments, err
+            }
+            continue
+        }
+
+        // The rest of the argument, or else the next one, is the value.
+        value := arg[i+1:]
+        if value == "" {
+            if len(arguments) == 0 {
+                return arguments, fmt.Errorf("flag needs an argument: -%s", name)
+            }
+            value, arguments = arguments[0], arguments[1:]
+        }
+        return arguments, c.setFlag(config, "-"+name, name, value)
+    }
+    return arguments, nil
+}
+
+// lookupCommandLine returns the flag with the given name, shorthand or
+// alias, in c or its parent sets, returning nil if there is none.
+func (c *ConfigoSet) lookupCommandLine(name string) *Configo {
+    _, config := c.lookupInherited(name)
+    if config == nil || !config.IsFlag {
+        return nil
+    }
+    return config
+}
+
+// setFlag sets config from the command line, where it was given as arg.
+func (c *ConfigoSet) setFlag(config *Configo, arg, name, value string) error {
+    if err := config.Value.Set(value); err != nil {
+        return fmt.Errorf("invalid value %q for flag %s: %v", value, arg, err)
+    }
+    set, _ := c.lookupInherited(name)
+    set.warnDeprecated(name, "command line")
+    set.mark(config, sourceFlag)
+    c.nflag++
+    return nil
+}
+
+// failf handles an error parsing the command line according to the error
+// handling of c.
+func (c *ConfigoSet) failf(err error) error {
+    if !errors.Is(err, flag.ErrHelp) {
+        fmt.Fprintln(c.out(), err)
+    }
+    c.usage()
+
+    switch c.errorHandling {
+    case flag.ExitOnError:
+        if errors.Is(err, flag.ErrHelp) {
+            os.Exit(0)
+        }
+        os.Exit(2)
+    case flag.PanicOnError:
+        panic(err)
+    }
+    return err
+}
+
+// usage calls the Usage function of c, or prints the default usage message.
+// The default set calls flag.Usage instead, which prints the default usage
+// message unless the program has changed it.
+func (c *ConfigoSet) usage() {
+    if c.Usage != nil {
+        c.Usage()
+        return
+    }
+    if c.flags == flag.CommandLine {
+        flag.Usage()
+        return
+    }
+    c.printUsage()
+}
+
+// printUsage prints the default usage message.
+func (c *ConfigoSet) printUsage() {
+    if err := c.WriteUsage(c.out()); err != nil {
+        fmt.Fprintln(c.out(), err)
+    }
+}
+
+func isBoolFlag(config *Configo) bool {
+    b, ok := config.Value.(boolFlag)
+    return ok && b.IsBoolFlag()
+}
@@ -0,0 +1,117 @@
+package configo
+
+import (
+    "errors"
+    "flag"
+    "io/ioutil"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestGNUSyntax(t *testing.T) {
+    tests := []struct {
+        args    []string
+        verbose bool
+        all     bool
+        name    string
+        port    int
+        rest    []string
+        nflag   int
+        set     []string // the items whose source is the command line
+        wantErr string
+    }{
+        {args: []string{}, verbose: true, rest: []string{}},
+        {args: []string{"-va"}, verbose: true, all: true, nflag: 2, set: []string{"verbose", "all"}},
+        {args: []string{"-avngopher"}, verbose: true, all: true, name: "gopher", nflag: 3, set: []string{"verbose", "all", "name"}},
+        {args: []string{"-n", "gopher"}, verbose: true, name: "gopher", nflag: 1, set: []string{"name"}},
+        {args: []string{"-an", "gopher", "x"}, verbose: true, all: true, name: "gopher", rest: []string{"x"}, nflag: 2, set: []string{"all", "name"}},
+        {args: []string{"--name=a=b"}, verbose: true, name: "a=b", nflag: 1, set: []string{"name"}},
+        {args: []string{"--name", "gopher"}, verbose: true, name: "gopher", nflag: 1, set: []string{"name"}},
+        {args: []string{"--port", "8080", "--all"}, verbose: true, all: true, port: 8080, nflag: 2, set: []string{"port", "all"}},
+        {args: []string{"--no-verbose"}, nflag: 1, set: []string{"verbose"}},
+        {args: []string{"--verbose=false", "--all=true"}, all: true, nflag: 2, set: []string{"verbose", "all"}},
+        {args: []string{"a", "--all", "b", "-n", "c", "d"}, verbose: true, all: true, name: "c", rest: []string{"a", "b", "d"}, nflag: 2, set: []string{"all", "name"}},
+        {args: []string{"--all", "--", "--name", "-v"}, verbose: true, all: true, rest: []string{"--name", "-v"}, nflag: 1, set: []string{"all"}},
+        {args: []string{"-"}, verbose: true, rest: []string{"-"}},
+        {args: []string{"--no-port"}, wantErr: "flag provided but not defined: --no-port"},
+        {args: []string{"--no-such"}, wantErr: "flag provided but not defined: --no-such"},
+        {args: []string{"-x"}, wantErr: "flag provided but not defined: -x"},
+        {args: []string{"-ax"}, wantErr: "flag provided but not defined: -x"},
+        {args: []string{"--name"}, wantErr: "flag needs an argument: --name"},
+        {args: []string{"-an"}, wantErr: "flag needs an argument: -n"},
+        {args: []string{"--port", "many"}, wantErr: `invalid value "many" for flag --port`},
+        {args: []string{"-h"}, wantErr: flag.ErrHelp.Error()},
+        {args: []string{"--help"}, wantErr: flag.ErrHelp.Error()},
+    }
+    for _, test := range tests {
+        c := NewConfigoSet("test", flag.ContinueOnError, "")
+        c.SetOutput(ioutil.Discard)
+        c.SetSyntax(GNUSyntax)
+        verbose := c.BoolFlag("verbose", true, "be verbose")
+        c.Shorthand("verbose", "v")
+        all := c.BoolFlag("all", false, "all of them")
+        c.Shorthand("all", "a")
+        name := c.StringFlag("name", "", "the name")
+        c.Shorthand("name", "n")
+        port := c.IntFlag("port", 0, "the port")
+        c.SetArguments(test.args)
+
+        err := c.parseFlags()
+        if test.wantErr != "" {
+            if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+                t.Errorf("%q: error %v, want %q", test.args, err, test.wantErr)
+            }
+            if test.wantErr == flag.ErrHelp.Error() && !errors.Is(err, flag.ErrHelp) {
+                t.Errorf("%q: error %v is not flag.ErrHelp", test.args, err)
+            }
+            continue
+        }
+        if err != nil {
+            t.Errorf("%q: %v", test.args, err)
+            continue
+        }
+        if *verbose != test.verbose || *all != test.all || *name != test.name || *port != test.port {
+            t.Errorf("%q: verbose %v all %v name %q port %d, want %v %v %q %d", test.args,
+                *verbose, *all, *name, *port, test.verbose, test.all, test.name, test.port)
+        }
+        if len(c.Args()) > 0 || len(test.rest) > 0 {
+            if !reflect.DeepEqual(c.Args(), test.rest) {
+                t.Errorf("%q: Args() = %q, want %q", test.args, c.Args(), test.rest)
+            }
+        }
+        if c.NFlag() != test.nflag {
+            t.Errorf("%q: NFlag() = %d, want %d", test.args, c.NFlag(), test.nflag)
+        }
+        c.VisitAll(func(config *Configo) {
+            want := sourceDefault
+            for _, name := range test.set {
+                if name == config.Name {
+                    want = sourceFlag
+                }
+            }
+            if config.Source() != want {
+                t.Errorf("%q: %s came from %s, want %s", test.args, config.Name, config.Source(), want)
+            }
+        })
+    }
+}
+
+func TestGNUSyntaxStopsAtSubcommand(t *testing.T) {
+    root := NewCommand("prog", flag.ContinueOnError, "")
+    root.SetOutput(ioutil.Discard)
+    root.SetSyntax(GNUSyntax)
+    verbose := root.BoolFlag("verbose", false, "be verbose")
+    root.AddCommand("serve", "serve it", nil)
+
+    root.SetArguments([]string{"--verbose", "serve", "--port", "80", "x"})
+    if err := root.parseFlags(); err != nil {
+        t.Fatal(err)
+    }
+    if !*verbose || root.NFlag() != 1 {
+        t.Errorf("verbose %v NFlag() %d, want true 1", *verbose, root.NFlag())
+    }
+    if want := []string{"serve", "--port", "80", "x"}; !reflect.DeepEqual(root.Args(), want) {
+        t.Errorf("Args() = %q, want %q", root.Args(), want)
+    }
+}
@@ -0,0 +1,795 @@
+package configo
+
+import (
+    "fmt"
+    "io"
+    "io/ioutil"
+    "math"
+    "strconv"
+    "strings"
+    "time"
+    "unicode/utf8"
+)
+
+// tomlFormat reads and writes TOML configuration files.
+type tomlFormat struct{}
+
+// Decode reads the key/value pairs of a TOML document.
+func (tomlFormat) Decode(r io.Reader) ([]Entry, error) {
+    content, err := ioutil.ReadAll(r)
+    if err != nil {
+        return nil, err
+    }
+    return parseTOML(content)
+}
+
+// Encode writes configs as a commented TOML document.
+func (tomlFormat) Encode(w io.Writer, configs []*Configo) error {
+    return writeTOML(w, configs)
+}
+
+// CommentPrefix returns the prefix of a comment line.
+func (tomlFormat) CommentPrefix() string {
+    return "#"
+}
+
+// Edit sets the value of config in the TOML document content.  An existing
+// value is replaced where it stands.  A missing key is added to the end of the
+// table it belongs in, or in a new table at the end of the document.
+func (tomlFormat) Edit(content []byte, config *Configo, value string) ([]byte, error) {
+    p, err := newTOMLParser(content)
+    if err != nil {
+        return nil, err
+    }
+    rendered := tomlValue(config, value)
+
+    for i := len(p.entries) - 1; i >= 0; i-- {
+        if p.entries[i].Name == config.Name {
+            span := p.spans[i]
+            return []byte(p.src[:span[0]] + rendered + p.src[span[1]:]), nil
+        }
+    }
+
+    // Find the most specific table the item belongs in.
+    table := &p.tables[0]
+    for i := range p.tables {
+        t := &p.tables[i]
+        if strings.HasPrefix(config.Name, t.name+".") && len(t.name) > len(table.name) {
+            table = t
+        }
+    }
+
+    key := config.Name
+    if table.name != "" {
+        key = key[len(table.name)+1:]
+    }
+    line := tomlKey(key) + " = " + rendered + "\n"
+
+    at := table.end
+    if table.name == "" && strings.Contains(key, ".") {
+        // Start a new table rather than adding a dotted key to the root.
+        i := strings.LastIndex(key, ".")
+        at = len(p.src)
+        line = "\n[" + tomlKey(key[:i]) + "]\n" + tomlKey(key[i+1:]) + " = " + rendered + "\n"
+    } else if at < 0 {
+        // The root table is empty, so add the key before the first table.
+        at = len(p.src)
+        if len(p.tables) > 1 {
+            at = p.tables[1].start
+        }
+    }
+
+    before := p.src[:at]
+    if before != "" && !strings.HasSuffix(before, "\n") {
+        before += "\n"
+    }
+    return []byte(before + line + p.src[at:]), nil
+}
+
+// tomlParser is a small, dependency free TOML reader.  Tables and dotted keys
+// are flattened into dotted configuration item names and arrays are flattened
+// into a single comma separated value, which is how a slice valued flag.Value
+// expects to receive them.
+type tomlParser struct {
+    src     string
+    pos     int
+    line    int
+    table   []string
+    keys    map[string]int
+    entries []Entry
+
+    // The offsets of each entry's value in src, and the tables in the order
+    // they are defined, for editing the document in place.
+    spans  [][2]int
+    tables []tomlTable
+}
+
+// tomlTable records where a table is defined in a TOML document.  The root
+// table has an empty name and start is the offset of the table header.  End
+// is the offset just after the last line of the table's header or key/value
+// pairs, or -1 if the root table has no key/value pairs.
+type tomlTable struct {
+    name  string
+    start int
+    end   int
+}
+
+// parseTOML parses the TOML document in content and returns the key/value
+// pairs it defines in the order they appear.
+func parseTOML(content []byte) ([]Entry, error) {
+    p, err := newTOMLParser(content)
+    if err != nil {
+        return nil, err
+    }
+    return p.entries, nil
+}
+
+// newTOMLParser returns a parser which has parsed content.
+func newTOMLParser(content []byte) (*tomlParser, error) {
+    p := &tomlParser{
+        src:    string(content),
+        line:   1,
+        keys:   make(map[string]int),
+        tables: []tomlTable{{end: -1}},
+    }
+    if err := p.parse(); err != nil {
+        return nil, err
+    }
+    return p, nil
+}
+
+// errorf returns an error at the current line and column.
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+    return tomlError(p.line, p.column(), format, args...)
+}
+
+// tomlError is an error at a particular line and column of a TOML document.
+func tomlError(line, column int, format string, args ...interface{}) error {
+    return fmt.Errorf("line %d, column %d: %s", line, column, fmt.Sprintf(format, args...))
+}
+
+// column returns the column of the current position, counting bytes from 1.
+func (p *tomlParser) column() int {
+    pos := p.pos
+    if pos > len(p.src) {
+        pos = len(p.src)
+    }
+    return pos - strings.LastIndexByte(p.src[:pos], '\n')
+}
+
+func (p *tomlParser) eof() bool {
+    return p.pos >= len(p.src)
+}
+
+func (p *tomlParser) peek() byte {
+    if p.eof() {
+        return 0
+    }
+    return p.src[p.pos]
+}
+
+func (p *tomlParser) hasPrefix(s string) bool {
+    return strings.HasPrefix(p.src[p.pos:], s)
+}
+
+// skipSpace skips spaces and tabs on the current line.
+func (p *tomlParser) skipSpace() {
+    for !p.eof() && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+        p.pos++
+    }
+}
+
+// skipComment skips a comment up to, but not including, the end of the line.
+func (p *tomlParser) skipComment() {
+    if p.peek() != '#' {
+        return
+    }
+    for !p.eof() && p.src[p.pos] != '\n' {
+        p.pos++
+    }
+}
+
+// newline consumes a line ending if there is one.
+func (p *tomlParser) newline() bool {
+    if p.hasPrefix("\r\n") {
+        p.pos += 2
+    } else if p.peek() == '\n' {
+        p.pos++
+    } else {
+        return false
+    }
+    p.line++
+    return true
+}
+
+// skipBlank skips whitespace, comments and line endings.
+func (p *tomlParser) skipBlank() {
+    for {
+        p.skipSpace()
+        p.skipComment()
+        if !p.newline() {
+            return
+        }
+    }
+}
+
+// endOfLine expects nothing but whitespace and an optional comment before the
+// end of the line.
+func (p *tomlParser) endOfLine() error {
+    p.skipSpace()
+    p.skipComment()
+    if p.eof() || p.newline() {
+        return nil
+    }
+    return p.errorf("unexpected %q after value", p.peek())
+}
+
+func (p *tomlParser) parse() error {
+    for {
+        p.skipBlank()
+        if p.eof() {
+            return nil
+        }
+
+        var err error
+        if p.peek() == '[' {
+            err = p.parseTable()
+        } else {
+            err = p.parseKeyValue(p.table)
+        }
+        if err != nil {
+            return err
+        }
+        if err = p.endOfLine(); err != nil {
+            return err
+        }
+        p.tables[len(p.tables)-1].end = p.pos
+    }
+}
+
+func (p *tomlParser) parseTable() error {
+    start := p.pos
+    p.pos++
+    if p.peek() == '[' {
+        return p.errorf("arrays of tables are not supported")
+    }
+    p.skipSpace()
+    key, err := p.parseKey()
+    if err != nil {
+        return err
+    }
+    p.skipSpace()
+    if p.peek() != ']' {
+        return p.errorf("expected ']' to close table header")
+    }
+    p.pos++
+    p.table = key
+    p.tables = append(p.tables, tomlTable{name: strings.Join(key, "."), start: start})
+    return nil
+}
+
+// parseKey parses a possibly dotted key and returns its parts.
+func (p *tomlParser) parseKey() ([]string, error) {
+    var parts []string
+    for {
+        p.skipSpace()
+        var part string
+        switch c := p.peek(); {
+        case c == '"':
+            s, err := p.parseBasicString()
+            if err != nil {
+                return nil, err
+            }
+            part = s
+        case c == '\'':
+            s, err := p.parseLiteralString()
+            if err != nil {
+                return nil, err
+            }
+            part = s
+        case isBareKeyChar(c):
+            start := p.pos
+            for !p.eof() && isBareKeyChar(p.src[p.pos]) {
+                p.pos++
+            }
+            part = p.src[start:p.pos]
+        default:
+            return nil, p.errorf("expected a key but found %q", c)
+        }
+        parts = append(parts, part)
+
+        p.skipSpace()
+        if p.peek() != '.' {
+            return parts, nil
+        }
+        p.pos++
+    }
+}
+
+func isBareKeyChar(c byte) bool {
+    return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *tomlParser) parseKeyValue(prefix []string) error {
+    column := p.column()
+    key, err := p.parseKey()
+    if err != nil {
+        return err
+    }
+    p.skipSpace()
+    if p.peek() != '=' {
+        return p.errorf("expected '=' after key %s", strings.Join(key, "."))
+    }
+    p.pos++
+    p.skipSpace()
+
+    path := append(append([]string{}, prefix...), key...)
+    if p.peek() == '{' {
+        return p.parseInlineTable(path)
+    }
+
+    line := p.line
+    start := p.pos
+    value, err := p.parseValue()
+    if err != nil {
+        return err
+    }
+    p.spans = append(p.spans, [2]int{start, p.pos})
+    return p.add(path, value, line, column)
+}
+
+// add records a value, rejecting keys that have already been defined.
+func (p *tomlParser) add(path []string, value string, line, column int) error {
+    name := strings.Join(path, ".")
+    if prev, exists := p.keys[name]; exists {
+        return tomlError(line, column, "key %s already defined on line %d", name, prev)
+    }
+    p.keys[name] = line
+    p.entries = append(p.entries, Entry{Name: name, Value: value, Line: line})
+    return nil
+}
+
+func (p *tomlParser) parseInlineTable(path []string) error {
+    p.pos++
+    p.skipSpace()
+    if p.peek() == '}' {
+        p.pos++
+        return nil
+    }
+    for {
+        if err := p.parseKeyValue(path); err != nil {
+            return err
+        }
+        p.skipSpace()
+        switch p.peek() {
+        case ',':
+            p.pos++
+            p.skipSpace()
+        case '}':
+            p.pos++
+            return nil
+        default:
+            return p.errorf("expected ',' or '}' in inline table")
+        }
+    }
+}
+
+// parseValue parses a single value and returns it in the textual form that
+// the flag.Value types in this package understand.
+func (p *tomlParser) parseValue() (string, error) {
+    switch c := p.peek(); {
+    case c == '"':
+        if p.hasPrefix(`"""`) {
+            return p.parseMultilineBasicString()
+        }
+        return p.parseBasicString()
+    case c == '\'':
+        if p.hasPrefix("'''") {
+            return p.parseMultilineLiteralString()
+        }
+        return p.parseLiteralString()
+    case c == '[':
+        return p.parseArray()
+    case c == '{':
+        return "", p.errorf("inline tables are not supported inside arrays")
+    case p.hasPrefix("true"):
+        p.pos += len("true")
+        return "true", nil
+    case p.hasPrefix("false"):
+        p.pos += len("false")
+        return "false", nil
+    case c == 0 || c == '\n' || c == '\r' || c == '#':
+        return "", p.errorf("missing value")
+    }
+    return p.parseScalar()
+}
+
+func (p *tomlParser) parseBasicString() (string, error) {
+    p.pos++
+    var b strings.Builder
+    for {
+        if p.eof() || p.peek() == '\n' {
+            return "", p.errorf("unterminated string")
+        }
+        c := p.src[p.pos]
+        switch c {
+        case '"':
+            p.pos++
+            return b.String(), nil
+        case '\\':
+            if err := p.parseEscape(&b); err != nil {
+                return "", err
+            }
+        default:
+            b.WriteByte(c)
+            p.pos++
+        }
+    }
+}
+
+func (p *tomlParser) parseMultilineBasicString() (string, error) {
+    p.pos += 3
+    p.newline()
+    var b strings.Builder
+    for {
+        if p.eof() {
+            return "", p.errorf("unterminated multi-line string")
+        }
+        if p.hasPrefix(`"""`) {
+            p.pos += 3
+            // Up to two quotes may directly precede the closing delimiter.
+            for i := 0; i < 2 && p.peek() == '"'; i++ {
+                b.WriteByte('"')
+                p.pos++
+            }
+            return b.String(), nil
+        }
+        c := p.src[p.pos]
+        switch {
+        case c == '\\' && p.lineEndingBackslash():
+            p.pos++
+            p.skipBlankSpace()
+        case c == '\\':
+            if err := p.parseEscape(&b); err != nil {
+                return "", err
+            }
+        case p.newline():
+            b.WriteByte('\n')
+        default:
+            b.WriteByte(c)
+            p.pos++
+        }
+    }
+}
+
+// lineEndingBackslash reports whether the backslash at the current position
+// is followed only by whitespace up to the end of the line.
+func (p *tomlParser) lineEndingBackslash() bool {
+    rest := p.src[p.pos+1:]
+    i := strings.IndexByte(rest, '\n')
+    if i < 0 {
+        return false
+    }
+    return strings.TrimRight(rest[:i], " \t\r") == ""
+}
+
+// skipBlankSpace skips all whitespace including line endings.
+func (p *tomlParser) skipBlankSpace() {
+    for {
+        p.skipSpace()
+        if !p.newline() {
+            return
+        }
+    }
+}
+
+func (p *tomlParser) parseEscape(b *strings.Builder) error {
+    p.pos++
+    if p.eof() {
+        return p.errorf("unterminated escape sequence")
+    }
+    c := p.src[p.pos]
+    p.pos++
+    switch c {
+    case 'b':
+        b.WriteByte('\b')
+    case 't':
+        b.WriteByte('\t')
+    case 'n':
+        b.WriteByte('\n')
+    case 'f':
+        b.WriteByte('\f')
+    case 'r':
+        b.WriteByte('\r')
+    case 'e':
+        b.WriteByte(0x1b)
+    case '"':
+        b.WriteByte('"')
+    case '\\':
+        b.WriteByte('\\')
+    case 'u', 'U':
+        n := 4
+        if c == 'U' {
+            n = 8
+        }
+        if p.pos+n > len(p.src) {
+            return p.errorf("invalid unicode escape")
+        }
+        r, err := strconv.ParseUint(p.src[p.pos:p.pos+n], 16, 32)
+        if err != nil || !utf8.ValidRune(rune(r)) {
+            return p.errorf("invalid unicode escape \\%c%s", c, p.src[p.pos:p.pos+n])
+        }
+        b.WriteRune(rune(r))
+        p.pos += n
+    default:
+        return p.errorf("invalid escape sequence \\%c", c)
+    }
+    return nil
+}
+
+func (p *tomlParser) parseLiteralString() (string, error) {
+    p.pos++
+    start := p.pos
+    for {
+        if p.eof() || p.peek() == '\n' {
+            return "", p.errorf("unterminated string")
+        }
+        if p.peek() == '\'' {
+            s := p.src[start:p.pos]
+            p.pos++
+            return s, nil
+        }
+        p.pos++
+    }
+}
+
+func (p *tomlParser) parseMultilineLiteralString() (string, error) {
+    p.pos += 3
+    p.newline()
+    var b strings.Builder
+    for {
+        if p.eof() {
+            return "", p.errorf("unterminated multi-line string")
+        }
+        if p.hasPrefix("'''") {
+            p.pos += 3
+            for i := 0; i < 2 && p.peek() == '\''; i++ {
+                b.WriteByte('\'')
+                p.pos++
+            }
+            return b.String(), nil
+        }
+        if p.newline() {
+            b.WriteByte('\n')
+            continue
+        }
+        b.WriteByte(p.src[p.pos])
+        p.pos++
+    }
+}
+
+// parseArray parses an array and joins its elements with commas.
+func (p *tomlParser) parseArray() (string, error) {
+    p.pos++
+    var items []string
+    for {
+        p.skipBlank()
+        if p.peek() == ']' {
+            p.pos++
+            return strings.Join(items, ","), nil
+        }
+        if p.peek() == '[' {
+            return "", p.errorf("nested arrays are not supported")
+        }
+        item, err := p.parseValue()
+        if err != nil {
+            return "", err
+        }
+        items = append(items, item)
+
+        p.skipBlank()
+        switch p.peek() {
+        case ',':
+            p.pos++
+        case ']':
+        default:
+            return "", p.errorf("expected ',' or ']' in array")
+        }
+    }
+}
+
+// parseScalar parses numbers, dates and times.
+func (p *tomlParser) parseScalar() (string, error) {
+    start := p.pos
+    for !p.eof() && isScalarChar(p.src[p.pos]) {
+        p.pos++
+    }
+    // A local date may be separated from its time by a single space.
+    if p.pos-start == 10 && p.peek() == ' ' && p.pos+1 < len(p.src) && isDigit(p.src[p.pos+1]) {
+        p.pos++
+        for !p.eof() && isScalarChar(p.src[p.pos]) {
+            p.pos++
+        }
+    }
+    token := p.src[start:p.pos]
+    if token == "" {
+        return "", p.errorf("unexpected %q", p.peek())
+    }
+
+    if value, ok := tomlDateTime(token); ok {
+        return value, nil
+    }
+
+    switch strings.TrimLeft(token, "+-") {
+    case "inf":
+        return strings.TrimSuffix(token, "inf") + "Inf", nil
+    case "nan":
+        return "NaN", nil
+    }
+
+    if strings.Contains(token, "__") || strings.HasPrefix(token, "_") || strings.HasSuffix(token, "_") {
+        return "", p.errorf("invalid number %s", token)
+    }
+    digits := strings.Replace(token, "_", "", -1)
+    if unsigned := strings.TrimLeft(digits, "+-"); len(unsigned) > 1 && unsigned[0] == '0' && isDigit(unsigned[1]) {
+        return "", p.errorf("leading zeros are not allowed in %s", token)
+    }
+    if i, err := strconv.ParseInt(digits, 0, 64); err == nil {
+        return strconv.FormatInt(i, 10), nil
+    }
+    if u, err := strconv.ParseUint(digits, 0, 64); err == nil {
+        return strconv.FormatUint(u, 10), nil
+    }
+    if !strings.ContainsAny(digits, "xXoObB") {
+        if _, err := strconv.ParseFloat(digits, 64); err == nil {
+            return digits, nil
+        }
+    }
+    return "", p.errorf("invalid value %s", token)
+}
+
+func isScalarChar(c byte) bool {
+    return isBareKeyChar(c) || c == '+' || c == '.' || c == ':'
+}
+
+func isDigit(c byte) bool {
+    return c >= '0' && c <= '9'
+}
+
+// tomlDateTime recognizes the TOML date and time forms.  Offset date-times are
+// returned in RFC 3339 form and local dates and times are returned as written,
+// using 'T' to separate the date from the time.
+func tomlDateTime(token string) (string, bool) {
+    if len(token) < 8 || !(token[4] == '-' || token[2] == ':') {
+        return "", false
+    }
+    token = strings.Replace(token, " ", "T", 1)
+    token = strings.Replace(token, "t", "T", 1)
+    token = strings.Replace(token, "z", "Z", 1)
+    if t, err := time.Parse(time.RFC3339Nano, token); err == nil {
+        return t.Format(time.RFC3339Nano), true
+    }
+    for _, layout := range []string{"2006-01-02T15:04:05.999999999", "2006-01-02", "15:04:05.999999999"} {
+        if _, err := time.Parse(layout, token); err == nil {
+            return token, true
+        }
+    }
+    return "", false
+}
+
+// writeTOML writes configs as a TOML document.  Items without a dot in their
+// name are written first, followed by a table for each dotted prefix.  Every
+// item is preceded by its usage text as a comment.
+func writeTOML(w io.Writer, configs []*Configo) error {
+    var tables []string
+    grouped := make(map[string][]*Configo)
+    for _, config := range configs {
+        table := ""
+        if i := strings.LastIndex(config.Name, "."); i >= 0 {
+            table = config.Name[:i]
+        }
+        if _, seen := grouped[table]; !seen && table != "" {
+            tables = append(tables, table)
+        }
+        grouped[table] = append(grouped[table], config)
+    }
+
+    if err := writeTOMLTable(w, grouped[""]); err != nil {
+        return err
+    }
+    for _, table := range tables {
+        if _, err := fmt.Fprintf(w, "[%s]\n\n", tomlKey(table)); err != nil {
+            return err
+        }
+        if err := writeTOMLTable(w, grouped[table]); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func writeTOMLTable(w io.Writer, configs []*Configo) error {
+    for _, config := range configs {
+        key := config.Name[strings.LastIndex(config.Name, ".")+1:]
+        _, err := fmt.Fprintf(w, "# %s\n%s = %s\n\n", config.Usage, tomlKey(key), tomlValue(config, config.DefaultValue))
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// tomlKey quotes the parts of a dotted key which are not valid bare keys.
+func tomlKey(key string) string {
+    parts := strings.Split(key, ".")
+    for i, part := range parts {
+        bare := part != ""
+        for j := 0; j < len(part); j++ {
+            bare = bare && isBareKeyChar(part[j])
+        }
+        if !bare {
+            parts[i] = tomlString(part)
+        }
+    }
+    return strings.Join(parts, ".")
+}
+
+// tomlValue renders value, a value of config, using the native TOML type for
+// the built in value types and a string for everything else.
+func tomlValue(config *Configo, value string) string {
+    switch config.Value.(type) {
+    case *boolValue, *intValue, *int64Value, *uintValue, *uint64Value:
+        return value
+    case *float64Value:
+        f, err := strconv.ParseFloat(value, 64)
+        if err != nil {
+            return tomlString(value)
+        }
+        switch {
+        case math.IsInf(f, 1):
+            return "inf"
+        case math.IsInf(f, -1):
+            return "-inf"
+        case math.IsNaN(f):
+            return "nan"
+        }
+        s := strconv.FormatFloat(f, 'g', -1, 64)
+        if !strings.ContainsAny(s, ".eE") {
+            s += ".0"
+        }
+        return s
+    }
+    return tomlString(value)
+}
+
+// tomlString returns s as a TOML basic string.
+func tomlString(s string) string {
+    var b strings.Builder
+    b.WriteByte('"')
+    for _, r := range s {
+        switch r {
+        case '"':
+            b.WriteString(`\"`)
+        case '\\':
+            b.WriteString(`\\`)
+        case '\b':
+            b.WriteString(`\b`)
+        case '\t':
+            b.WriteString(`\t`)
+        case '\n':
+            b.WriteString(`\n`)
+        case '\f':
+            b.WriteString(`\f`)
+        case '\r':
+            b.WriteString(`\r`)
+        default:
+            if r < 0x20 || r == 0x7f {
+                fmt.Fprintf(&b, `\u%04X`, r)
+            } else {
+                b.WriteRune(r)
+            }
+        }
+    }
+    b.WriteByte('"')
+    return b.String()
+}
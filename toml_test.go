@@ -0,0 +1,147 @@
+package configo
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestTOMLDecode(t *testing.T) {
+    tests := []struct {
+        desc string
+        src  string
+        want []Entry
+    }{
+        {"bare key", "port = 8080\n", []Entry{{"port", "8080", 1}}},
+        {"basic string", `name = "a \"b\" \\ c"`, []Entry{{"name", `a "b" \ c`, 1}}},
+        {"escapes", `s = "tab\there\n\u00e9\U0001F600"`, []Entry{{"s", "tab\there\n\u00e9\U0001F600", 1}}},
+        {"literal string", `path = 'C:\dir\"x"'`, []Entry{{"path", `C:\dir\"x"`, 1}}},
+        {"multi-line basic string", "s = \"\"\"\nab\\\n    cd\nef\"\"\"\nn = 1\n", []Entry{{"s", "abcd\nef", 1}, {"n", "1", 5}}},
+        {"multi-line literal string", "s = '''\nline1\n\\n'''\n", []Entry{{"s", "line1\n\\n", 1}}},
+        {"inline comment", "port = 80 # the port\nname = \"a # b\" # c\n", []Entry{{"port", "80", 1}, {"name", "a # b", 2}}},
+        {"comment lines", "# header\n\n  # indented\nport = 80\n", []Entry{{"port", "80", 4}}},
+        {"quoted keys", `"my key" = 1` + "\n" + `'lit.eral' = 2`, []Entry{{"my key", "1", 1}, {"lit.eral", "2", 2}}},
+        {"dotted key", "a.b . c = 1\n", []Entry{{"a.b.c", "1", 1}}},
+        {"tables", "top = 1\n[db]\nhost = \"h\"\n[db.replica]\nhost = \"r\"\n", []Entry{{"top", "1", 1}, {"db.host", "h", 3}, {"db.replica.host", "r", 5}}},
+        {"inline table", `db = { host = "h", port = 1 }`, []Entry{{"db.host", "h", 1}, {"db.port", "1", 1}}},
+        {"array", "ports = [1, 2, 3]\n", []Entry{{"ports", "1,2,3", 1}}},
+        {"multi-line array", "names = [\n  \"a\", # first\n  'b',\n]\n", []Entry{{"names", "a,b", 1}}},
+        {"empty array", "names = []\n", []Entry{{"names", "", 1}}},
+        {"booleans", "a = true\nb = false\n", []Entry{{"a", "true", 1}, {"b", "false", 2}}},
+        {"integers", "a = 1_000\nb = 0x1F\nc = 0o17\nd = 0b101\ne = -7\nf = 18446744073709551615\n",
+            []Entry{{"a", "1000", 1}, {"b", "31", 2}, {"c", "15", 3}, {"d", "5", 4}, {"e", "-7", 5}, {"f", "18446744073709551615", 6}}},
+        {"floats", "a = 1.5\nb = 1e3\nc = inf\nd = -inf\ne = nan\n",
+            []Entry{{"a", "1.5", 1}, {"b", "1e3", 2}, {"c", "Inf", 3}, {"d", "-Inf", 4}, {"e", "NaN", 5}}},
+        {"dates", "a = 1979-05-27T07:32:00Z\nb = 1979-05-27 07:32:00\nc = 1979-05-27\nd = 07:32:00\n",
+            []Entry{{"a", "1979-05-27T07:32:00Z", 1}, {"b", "1979-05-27T07:32:00", 2}, {"c", "1979-05-27", 3}, {"d", "07:32:00", 4}}},
+        {"CRLF", "a = 1\r\nb = \"x\"\r\n", []Entry{{"a", "1", 1}, {"b", "x", 2}}},
+        {"empty", "", nil},
+    }
+    for _, test := range tests {
+        got, err := tomlFormat{}.Decode(strings.NewReader(test.src))
+        if err != nil {
+            t.Errorf("%s: %v", test.desc, err)
+            continue
+        }
+        if !reflect.DeepEqual(got, test.want) {
+            t.Errorf("%s: got %+v, want %+v", test.desc, got, test.want)
+        }
+    }
+}
+
+func TestTOMLDecodeErrors(t *testing.T) {
+    tests := []struct {
+        desc string
+        src  string
+        want string
+    }{
+        {"duplicate key", "a = 1\na = 2\n", "line 2, column 1: key a already defined on line 1"},
+        {"duplicate in table", "a.b = 1\n[a]\n  b = 2\n", "line 3, column 3: key a.b already defined on line 1"},
+        {"duplicate in inline table", "a = { b = 1, b = 2 }\n", "line 1, column 14: key a.b already defined on line 1"},
+        {"unterminated string", "a = \"x\n", "line 1, column 7: unterminated string"},
+        {"unterminated literal", "a = 'x", "line 1, column 7: unterminated string"},
+        {"unterminated multi-line", "a = \"\"\"\nx\n", "line 3, column 1: unterminated multi-line string"},
+        {"missing value", "a =\n", "line 1, column 4: missing value"},
+        {"missing equals", "a 1\n", "line 1, column 3: expected '=' after key a"},
+        {"bad escape", `a = "\q"`, `line 1, column 8: invalid escape sequence \q`},
+        {"bad unicode", `a = "\uZZZZ"`, `line 1, column 8: invalid unicode escape \uZZZZ`},
+        {"text after value", "a = 1 2\n", "line 1, column 7: unexpected '2' after value"},
+        {"leading zero", "a = 01\n", "line 1, column 7: leading zeros are not allowed in 01"},
+        {"bad underscore", "a = 1__0\n", "line 1, column 9: invalid number 1__0"},
+        {"bad value", "a = yes\n", "line 1, column 8: invalid value yes"},
+        {"unclosed array", "a = [1, 2\nb = 3\n", "line 2, column 1: expected ',' or ']' in array"},
+        {"nested array", "a = [[1]]\n", "line 1, column 6: nested arrays are not supported"},
+        {"array of tables", "[[a]]\n", "line 1, column 2: arrays of tables are not supported"},
+        {"unclosed table", "[a\n", "line 1, column 3: expected ']' to close table header"},
+        {"bad key", "= 1\n", "line 1, column 1: expected a key but found '='"},
+    }
+    for _, test := range tests {
+        _, err := tomlFormat{}.Decode(strings.NewReader(test.src))
+        if err == nil || err.Error() != test.want {
+            t.Errorf("%s: error %v, want %q", test.desc, err, test.want)
+        }
+    }
+}
+
+func TestTOMLEdit(t *testing.T) {
+    c := NewConfigoSet("test", 0, "")
+    c.IntConfig("port", 80, "the port")
+    c.StringConfig("name", "", "the name")
+    c.StringConfig("db.host", "", "the database host")
+    c.StringConfig("db.user", "", "the database user")
+    c.Float64Config("rate", 0, "the rate")
+
+    tests := []struct {
+        desc  string
+        src   string
+        name  string
+        value string
+        want  string
+    }{
+        {"replace keeps comments", "# the port\nport = 80 # inline\n", "port", "90", "# the port\nport = 90 # inline\n"},
+        {"replace last", "port = 80\n[db]\nhost = 'h'\n", "db.host", "x", "port = 80\n[db]\nhost = \"x\"\n"},
+        {"replace in inline table", "db = { host = \"h\", user = \"u\" } # db\n", "db.user", "v", "db = { host = \"h\", user = \"v\" } # db\n"},
+        {"quote string", "name = \"a\"\n", "name", `say "hi"` + "\n", "name = \"say \\\"hi\\\"\\n\"\n"},
+        {"float", "rate = 1.0\n", "rate", "2.5", "rate = 2.5\n"},
+        {"whole float", "rate = 1.5\n", "rate", "2", "rate = 2.0\n"},
+        {"add to root", "# top\nname = \"a\"\n\n[db]\nhost = \"h\"\n", "port", "90", "# top\nname = \"a\"\nport = 90\n\n[db]\nhost = \"h\"\n"},
+        {"add before first table", "[db]\nhost = \"h\"\n", "port", "90", "port = 90\n[db]\nhost = \"h\"\n"},
+        {"add to table", "[db]\nhost = \"h\" # main\n\n# end\n", "db.user", "u", "[db]\nhost = \"h\" # main\nuser = \"u\"\n\n# end\n"},
+        {"add table", "port = 80\n", "db.user", "u", "port = 80\n\n[db]\nuser = \"u\"\n"},
+        {"add without newline", "port = 80", "name", "n", "port = 80\nname = \"n\"\n"},
+        {"empty", "", "port", "90", "port = 90\n"},
+    }
+    for _, test := range tests {
+        config := c.Lookup(test.name)
+        got, err := tomlFormat{}.Edit([]byte(test.src), config, test.value)
+        if err != nil {
+            t.Errorf("%s: %v", test.desc, err)
+            continue
+        }
+        if string(got) != test.want {
+            t.Errorf("%s: got %q, want %q", test.desc, got, test.want)
+            continue
+        }
+
+        // The edited document holds the new value, although a whole float is
+        // written as 2.0.
+        entries, err := parseTOML(got)
+        if err != nil {
+            t.Errorf("%s: edited document: %v", test.desc, err)
+            continue
+        }
+        value := ""
+        for _, e := range entries {
+            if e.Name == test.name {
+                value = e.Value
+            }
+        }
+        if want := strings.TrimSuffix(test.value, ".0"); strings.TrimSuffix(value, ".0") != want {
+            t.Errorf("%s: edited value %q, want %q", test.desc, value, test.value)
+        }
+    }
+
+    if _, err := (tomlFormat{}).Edit([]byte("a = \n"), c.Lookup("port"), "1"); err == nil {
+        t.Error("Edit of a malformed document succeeded")
+    }
+}
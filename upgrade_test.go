@@ -0,0 +1,81 @@
+package configo
+
+import (
+    "io/ioutil"
+    "path/filepath"
+    "testing"
+)
+
+func TestUpgradeConfig(t *testing.T) {
+    tests := []struct {
+        file   string
+        src    string
+        want   string
+        second string // after log.new is added too
+    }{
+        {
+            file: "rc",
+            src:  "# the port\nport=90\n\n[serve]\n# the host\nhost=h\n",
+            want: "# the port\nport=90\n# the log file\n#log.file=\n# the log level\n#log.level=info\n# the name\n#name=gopher\n\n" +
+                "[serve]\n# the host\nhost=h\n# the workers\n#workers=4\n",
+            second: "# the port\nport=90\n# the log file\n#log.file=\n# the log level\n#log.level=info\n# the name\n#name=gopher\n# the new item\n#log.new=\n\n" +
+                "[serve]\n# the host\nhost=h\n# the workers\n#workers=4\n",
+        },
+        {
+            file: "rc.toml",
+            src:  "# the port\nport = 90\n\n[serve]\n# the host\nhost = \"h\"\n",
+            want: "# the port\nport = 90\n# the name\n#name = \"gopher\"\n\n[serve]\n# the host\nhost = \"h\"\n# the workers\n#workers = 4\n\n" +
+                "#[log]\n# the log file\n#file = \"\"\n# the log level\n#level = \"info\"\n",
+            second: "# the port\nport = 90\n# the name\n#name = \"gopher\"\n\n[serve]\n# the host\nhost = \"h\"\n# the workers\n#workers = 4\n\n" +
+                "#[log]\n# the log file\n#file = \"\"\n# the log level\n#level = \"info\"\n# the new item\n#new = \"\"\n",
+        },
+        {
+            file: "rc.yaml",
+            src:  "# the port\nport: 90\nserve:\n  # the host\n  host: h\n",
+            want: "# the port\nport: 90\nserve:\n  # the host\n  host: h\n  # the workers\n#  workers: 4\n" +
+                "#log:\n  # the log file\n#  file: \"\"\n  # the log level\n#  level: \"info\"\n# the name\n#name: \"gopher\"\n",
+            second: "# the port\nport: 90\nserve:\n  # the host\n  host: h\n  # the workers\n#  workers: 4\n" +
+                "#log:\n  # the log file\n#  file: \"\"\n  # the log level\n#  level: \"info\"\n  # the new item\n#  new: \"\"\n" +
+                "# the name\n#name: \"gopher\"\n",
+        },
+    }
+    for _, test := range tests {
+        path := filepath.Join(t.TempDir(), test.file)
+        if err := ioutil.WriteFile(path, []byte(test.src), 0600); err != nil {
+            t.Fatal(err)
+        }
+        c := NewConfigoSet("test", 0, path)
+        c.IntConfig("port", 80, "the port")
+        c.StringConfig("name", "gopher", "the name")
+        c.StringConfig("serve.host", "", "the host")
+        c.IntConfig("serve.workers", 4, "the workers")
+        c.StringConfig("log.level", "info", "the log level")
+        c.StringConfig("log.file", "", "the log file")
+
+        for i, want := range []string{test.want, test.want, test.second} {
+            if i == 2 {
+                c.StringConfig("log.new", "", "the new item")
+            }
+            if err := c.UpgradeConfig(); err != nil {
+                t.Fatalf("%s: UpgradeConfig: %v", test.file, err)
+            }
+            got, err := ioutil.ReadFile(path)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if string(got) != want {
+                t.Errorf("%s: run %d wrote\n%s\nwant\n%s", test.file, i+1, got, want)
+            }
+        }
+
+        // The commented out items leave the values as they were.
+        r := NewConfigoSet("test", 0, path)
+        r.SetArguments([]string{})
+        port := r.IntConfig("port", 80, "the port")
+        workers := r.IntConfig("serve.workers", 1, "the workers")
+        r.StringConfig("serve.host", "", "the host")
+        if err := r.Parse(); err != nil || *port != 90 || *workers != 1 {
+            t.Errorf("%s: read back port %d workers %d: %v", test.file, *port, *workers, err)
+        }
+    }
+}
@@ -0,0 +1,288 @@
+package configo
+
+import (
+    "flag"
+    "fmt"
+    "io"
+    "os"
+    "sort"
+    "strconv"
+    "strings"
+    "text/template"
+)
+
+// UsageData is the data given to the usage template.
+type UsageData struct {
+    Name      string       // the name of the ConfigoSet
+    Synopsis  string       // the rest of the usage line, such as "[flags] src"
+    Title     string       // the heading of the flags without a group
+    Flags     []UsageGroup // the items which are command line flags
+    Config    []UsageGroup // the items which are only valid in the configuration file
+    Arguments []UsageItem  // the declared positional arguments
+    Width     int          // the width of the terminal
+}
+
+// UsageGroup is a group of items in the usage output.  Name is the category
+// of the items, or the section of their names, such as "gopher" for
+// "gopher.type"; it is "" for items in neither.
+type UsageGroup struct {
+    Name  string
+    Items []UsageItem
+}
+
+// UsageItem is a single item or positional argument in the usage output.
+type UsageItem struct {
+    Names    string // how it is written, such as "-g, -gopher_type"
+    Type     string // the type of value it takes, or "" for a boolean flag
+    Default  string // the default value, or "" if it is the zero value
+    Env      string // the environment variable it is taken from, or ""
+    Usage    string
+    Required bool
+    Config   *Configo // nil for a positional argument
+}
+
+// DefaultUsageTemplate is the template used by the default usage message.
+// It defines the "flags", "config" and "arguments" templates, which a
+// template set with SetUsageTemplate may use too.
+const DefaultUsageTemplate = `Usage: {{.Name}} {{.Synopsis}}
+{{template "arguments" .}}{{template "flags" .}}{{template "config" .}}
+{{- define "item"}}  {{.Names}}{{if .Type}} {{.Type}}{{end}}{{if .Env}} (${{.Env}}){{end}}{{if .Required}} (required){{end}}
+{{if .Default}}{{wrap (printf "%s (default %s)" .Usage .Default) 8}}{{else}}{{wrap .Usage 8}}{{end}}{{end}}
+{{- define "arguments"}}{{if .Arguments}}
+Arguments:
+{{range .Arguments}}{{template "item" .}}{{end}}{{end}}{{end}}
+{{- define "flags"}}{{range .Flags}}
+{{if .Name}}{{.Name}} flags{{else}}{{$.Title}}{{end}}:
+{{range .Items}}{{template "item" .}}{{end}}{{end}}{{end}}
+{{- define "config"}}{{if .Config}}
+Configuration file options:
+{{range .Config}}{{if .Name}}
+  [{{.Name}}]
+{{end}}{{range .Items}}{{template "item" .}}{{end}}{{end}}{{end}}{{end}}`
+
+// SetUsageTemplate sets the text/template used to print the default usage
+// message, which is given a UsageData.  The template may use the function
+// wrap, which wraps text to the terminal width and indents it, as in
+// {{wrap .Usage 8}}.
+func (c *ConfigoSet) SetUsageTemplate(text string) error {
+    tmpl, err := template.New("usage").Funcs(usageFuncs(0)).Parse(DefaultUsageTemplate)
+    if err == nil {
+        tmpl, err = tmpl.New("custom").Parse(text)
+    }
+    if err != nil {
+        return err
+    }
+    c.usageTemplate = tmpl
+    return nil
+}
+
+// SetUsageTemplate sets the text/template used to print the default usage
+// message.
+func SetUsageTemplate(text string) error {
+    return configuration.SetUsageTemplate(text)
+}
+
+// SetCategory puts the named items in a category, which groups them in the
+// usage output.  Items without a category are grouped by the section of
+// their names, such as "gopher" for "gopher.type".
+func (c *ConfigoSet) SetCategory(category string, names ...string) {
+    for _, name := range names {
+        config, ok := c.formal[name]
+        if !ok {
+            panic(fmt.Sprintf("%s category %s for unknown item %s", c.name, category, name))
+        }
+        config.Category = category
+    }
+}
+
+// SetCategory puts the named items in a category, which groups them in the
+// usage output.
+func SetCategory(category string, names ...string) {
+    configuration.SetCategory(category, names...)
+}
+
+// WriteUsage writes the usage message to w: the usage line, the positional
+// arguments, the flags grouped by category and the items which can only be
+// set in the configuration file, wrapped to the width of the terminal.
+func (c *ConfigoSet) WriteUsage(w io.Writer) error {
+    return c.executeUsage(w, "", "Flags")
+}
+
+// WriteUsage writes the usage message to w.
+func WriteUsage(w io.Writer) error {
+    return configuration.WriteUsage(w)
+}
+
+// executeUsage executes the named template of the usage template, or the
+// usage template itself if name is "", with title as the heading of the flags
+// without a group.
+func (c *ConfigoSet) executeUsage(w io.Writer, name, title string) error {
+    data := c.usageData()
+    data.Title = title
+    tmpl := c.usageTemplate
+    if tmpl == nil {
+        tmpl = template.Must(template.New("usage").Funcs(usageFuncs(0)).Parse(DefaultUsageTemplate))
+    } else if name == "" && tmpl.Lookup("custom") != nil {
+        name = "custom"
+    }
+    tmpl = template.Must(tmpl.Clone()).Funcs(usageFuncs(data.Width))
+    if name == "" {
+        return tmpl.Execute(w, data)
+    }
+    return tmpl.ExecuteTemplate(w, name, data)
+}
+
+// usageData collects the items of c for the usage template.
+func (c *ConfigoSet) usageData() *UsageData {
+    data := &UsageData{Name: c.name, Synopsis: "[flags]", Width: terminalWidth()}
+    if synopsis := c.argumentsSynopsis(); synopsis != "" {
+        data.Synopsis += " " + synopsis
+    }
+
+    for _, arg := range c.positionals {
+        item := UsageItem{Names: arg.Name, Usage: arg.Usage, Required: !arg.Optional}
+        if arg.Value != nil {
+            item.Type = typeName(arg.Value)
+        }
+        data.Arguments = append(data.Arguments, item)
+    }
+
+    flags := make(map[string][]UsageItem)
+    config := make(map[string][]UsageItem)
+    c.VisitAll(func(item *Configo) {
+        if item.Hidden || c.isDeprecated(item.Name) {
+            return
+        }
+        if item.IsFlag {
+            group := usageGroup(item)
+            usage := UsageItem{
+                Names:   c.flagNames(item),
+                Type:    typeName(item.Value),
+                Default: defaultText(item),
+                Usage:   item.Usage,
+                Config:  item,
+            }
+            if c.profileFlags && item.Name == profileFlagName {
+                usage.Env = c.profileEnvVar()
+            }
+            flags[group] = append(flags[group], usage)
+        } else if item.IsConfig {
+            // The configuration file has sections, not categories.
+            section, name := "", item.Name
+            if i := strings.LastIndex(name, "."); i >= 0 {
+                section, name = name[:i], name[i+1:]
+            }
+            config[section] = append(config[section], UsageItem{
+                Names:   name,
+                Type:    typeName(item.Value),
+                Default: defaultText(item),
+                Usage:   item.Usage,
+                Config:  item,
+            })
+        }
+    })
+    data.Flags = usageGroups(flags)
+    data.Config = usageGroups(config)
+    return data
+}
+
+// usageGroup returns the group of config in the usage output.
+func usageGroup(config *Configo) string {
+    if config.Category != "" {
+        return config.Category
+    }
+    if i := strings.LastIndex(config.Name, "."); i >= 0 {
+        return config.Name[:i]
+    }
+    return ""
+}
+
+// usageGroups returns the groups in order, with the unnamed group first.
+func usageGroups(items map[string][]UsageItem) []UsageGroup {
+    names := make([]string, 0, len(items))
+    for name := range items {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    groups := make([]UsageGroup, 0, len(names))
+    for _, name := range names {
+        groups = append(groups, UsageGroup{Name: name, Items: items[name]})
+    }
+    return groups
+}
+
+// typeName returns the name of the type of value for usage output, or "" for
+// a boolean flag, which takes no value.
+func typeName(value flag.Value) string {
+    if b, ok := value.(boolFlag); ok && b.IsBoolFlag() {
+        return ""
+    }
+    switch value.(type) {
+    case *intValue, *int64Value:
+        return "int"
+    case *uintValue, *uint64Value:
+        return "uint"
+    case *stringValue, *secretValue:
+        return "string"
+    case *float64Value:
+        return "float"
+    case *durationValue:
+        return "duration"
+    }
+    return "value"
+}
+
+// defaultText returns the default value of config for usage output, or "" if
+// it is the zero value of its type.
+func defaultText(config *Configo) string {
+    switch config.DefaultValue {
+    case "", "0", "false", "0s", "[]":
+        return ""
+    }
+    if _, ok := config.Value.(*stringValue); ok {
+        return strconv.Quote(config.DefaultValue)
+    }
+    return config.DefaultValue
+}
+
+// terminalWidth returns the width of the terminal from $COLUMNS, or 80.
+func terminalWidth() int {
+    if width, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && width > 0 {
+        return width
+    }
+    return 80
+}
+
+// usageFuncs returns the functions available to the usage template.
+func usageFuncs(width int) template.FuncMap {
+    return template.FuncMap{
+        "wrap": func(text string, indent int) string {
+            return wrap(text, indent, width)
+        },
+    }
+}
+
+// wrap breaks text into lines no wider than width, indented by indent
+// spaces, and ends each with a newline.  A word longer than a line is not
+// broken.
+func wrap(text string, indent, width int) string {
+    if text == "" {
+        return ""
+    }
+    prefix := strings.Repeat(" ", indent)
+    var b strings.Builder
+    line := prefix
+    for _, word := range strings.Fields(text) {
+        if line != prefix && len(line)+1+len(word) > width {
+            b.WriteString(line + "\n")
+            line = prefix
+        }
+        if line != prefix {
+            line += " "
+        }
+        line += word
+    }
+    b.WriteString(line + "\n")
+    return b.String()
+}
@@ -0,0 +1,76 @@
+package configo
+
+import (
+    "bytes"
+    "flag"
+    "strings"
+    "testing"
+)
+
+// newTestCommandLine returns a default set using a fresh flag.CommandLine,
+// which is restored when the test ends.
+func newTestCommandLine(t *testing.T) *ConfigoSet {
+    saved, savedUsage := flag.CommandLine, flag.Usage
+    flag.CommandLine = flag.NewFlagSet("prog", flag.ContinueOnError)
+    t.Cleanup(func() { flag.CommandLine, flag.Usage = saved, savedUsage })
+
+    c := newCommandLine()
+    c.name = "prog"
+    c.SetArguments(nil)
+    return c
+}
+
+func TestCommandLineHelp(t *testing.T) {
+    c := newTestCommandLine(t)
+    c.String("dbhost", "localhost", "the database host")
+    c.IntConfig("workers", 4, "the number of workers")
+    c.Bool("verbose", false, "print more")
+    c.SetCategory("Output", "verbose")
+    c.EnableCompletion()
+    c.EnableProfiles()
+
+    var out bytes.Buffer
+    c.SetOutput(&out)
+    c.SetArguments([]string{"-h"})
+    if err := c.Parse(); err != flag.ErrHelp {
+        t.Fatalf("Parse(-h) = %v, want %v", err, flag.ErrHelp)
+    }
+
+    usage := out.String()
+    for _, want := range []string{
+        "Usage: prog [flags]\n",
+        "Flags:\n  -dbhost string\n",
+        "Output flags:\n  -verbose\n",
+        "  -profile string ($PROG_PROFILE)\n",
+        "Configuration file options:\n  workers int\n",
+    } {
+        if !strings.Contains(usage, want) {
+            t.Errorf("usage does not contain %q:\n%s", want, usage)
+        }
+    }
+    for _, unwanted := range []string{"Usage of", "-completion", "-workers"} {
+        if strings.Contains(usage, unwanted) {
+            t.Errorf("usage contains %q:\n%s", unwanted, usage)
+        }
+    }
+}
+
+func TestCommandLineProgramUsage(t *testing.T) {
+    for _, syntax := range []Syntax{GoSyntax, GNUSyntax} {
+        c := newTestCommandLine(t)
+        c.String("dbhost", "localhost", "the database host")
+        c.SetSyntax(syntax)
+        c.errorHandling = flag.ContinueOnError
+
+        var out bytes.Buffer
+        c.SetOutput(&out)
+        flag.Usage = func() { out.WriteString("program usage\n") }
+        c.SetArguments([]string{"-h"})
+        if err := c.Parse(); err != flag.ErrHelp {
+            t.Fatalf("%v: Parse(-h) = %v, want %v", syntax, err, flag.ErrHelp)
+        }
+        if out.String() != "program usage\n" {
+            t.Errorf("%v: usage is\n%s\nwant the program's own", syntax, out.String())
+        }
+    }
+}
@@ -0,0 +1,713 @@
+package configo
+
+import (
+    "fmt"
+    "io"
+    "io/ioutil"
+    "strconv"
+    "strings"
+    "unicode/utf8"
+)
+
+// yamlFormat reads and writes YAML configuration files.
+type yamlFormat struct{}
+
+// Decode reads the key/value pairs of a YAML document.
+func (yamlFormat) Decode(r io.Reader) ([]Entry, error) {
+    content, err := ioutil.ReadAll(r)
+    if err != nil {
+        return nil, err
+    }
+    return parseYAML(content)
+}
+
+// Encode writes configs as a commented YAML document.
+func (yamlFormat) Encode(w io.Writer, configs []*Configo) error {
+    return writeYAML(w, configs)
+}
+
+// CommentPrefix returns the prefix of a comment line.
+func (yamlFormat) CommentPrefix() string {
+    return "#"
+}
+
+// Edit sets the value of config in the YAML document content.  An existing
+// value is replaced where it stands, but values spanning several lines cannot
+// be replaced.  A missing key is added to the end of the most specific
+// mapping it belongs in, creating nested mappings as needed.
+func (yamlFormat) Edit(content []byte, config *Configo, value string) ([]byte, error) {
+    p, err := newYAMLParser(content)
+    if err != nil {
+        return nil, err
+    }
+    src := string(content)
+    rendered := yamlValue(config, value)
+
+    for i := len(p.entries) - 1; i >= 0; i-- {
+        if p.entries[i].Name != config.Name {
+            continue
+        }
+        span := p.spans[i]
+        if span[0] < 0 {
+            return nil, fmt.Errorf("line %d: cannot replace the multi-line value of %s", p.entries[i].Line, config.Name)
+        }
+        if span[0] == span[1] {
+            rendered = " " + rendered
+        }
+        return []byte(src[:span[0]] + rendered + src[span[1]:]), nil
+    }
+
+    // Find the most specific mapping the item belongs in.
+    parts := strings.Split(config.Name, ".")
+    at, indent, k := len(src), 0, 0
+    for i := len(parts) - 1; i >= 0; i-- {
+        if m, ok := p.mappings[strings.Join(parts[:i], ".")]; ok {
+            indent, k = m.indent, i
+            // Keys of the root mapping go at the end of the document, after
+            // any comments following its last key.
+            if i > 0 {
+                at = m.end
+            }
+            break
+        }
+    }
+
+    var b strings.Builder
+    for i, part := range parts[k:] {
+        b.WriteString(strings.Repeat(" ", indent+2*i))
+        b.WriteString(yamlString(part, true))
+        if k+i < len(parts)-1 {
+            b.WriteString(":\n")
+        } else {
+            b.WriteString(": " + rendered + "\n")
+        }
+    }
+
+    before := src[:at]
+    if before != "" && !strings.HasSuffix(before, "\n") {
+        before += "\n"
+    }
+    return []byte(before + b.String() + src[at:]), nil
+}
+
+// yamlLine is a single line of a YAML document with its indentation removed.
+// Start is the offset in the document of the line's first column and end is
+// the offset just after its line ending.
+type yamlLine struct {
+    number int
+    indent int
+    text   string
+    start  int
+    end    int
+}
+
+// yamlMapping records where a block mapping is in a YAML document.  Indent is
+// the indentation of its keys and end is the offset just after its last line.
+type yamlMapping struct {
+    indent int
+    end    int
+}
+
+// yamlParser reads the subset of YAML that is useful for configuration
+// files: block mappings, block sequences, flow sequences, plain and quoted
+// scalars, literal and folded block scalars, and comments.  Nested mapping
+// keys are joined with dots to form configuration item names and sequences
+// are joined with commas to form a single value.
+type yamlParser struct {
+    lines   []yamlLine
+    pos     int
+    keys    map[string]int
+    entries []Entry
+
+    // The offsets of each entry's value, or -1 for values on several lines,
+    // and the mappings by name, for editing the document in place.
+    spans    [][2]int
+    mappings map[string]yamlMapping
+    lastEnd  int
+}
+
+// yamlError is an error at a particular line and column of a YAML document.
+func yamlError(line, column int, format string, args ...interface{}) error {
+    return fmt.Errorf("line %d, column %d: %s", line, column, fmt.Sprintf(format, args...))
+}
+
+// parseYAML parses the YAML document in content and returns the key/value
+// pairs it defines in the order they appear.
+func parseYAML(content []byte) ([]Entry, error) {
+    p, err := newYAMLParser(content)
+    if err != nil {
+        return nil, err
+    }
+    return p.entries, nil
+}
+
+// newYAMLParser returns a parser which has parsed content.
+func newYAMLParser(content []byte) (*yamlParser, error) {
+    p := &yamlParser{keys: make(map[string]int), mappings: make(map[string]yamlMapping)}
+    if err := p.split(string(content)); err != nil {
+        return nil, err
+    }
+    if p.pos < len(p.lines) {
+        first := p.lines[p.pos]
+        if strings.HasPrefix(first.text, "- ") || first.text == "-" {
+            return nil, yamlError(first.number, first.indent+1, "the document must be a mapping")
+        }
+        if err := p.parseMapping(first.indent, nil); err != nil {
+            return nil, err
+        }
+    }
+    if p.pos < len(p.lines) {
+        l := p.lines[p.pos]
+        return nil, yamlError(l.number, l.indent+1, "unexpected indentation")
+    }
+    return p, nil
+}
+
+// split breaks src into lines, dropping document markers.  Comments are left
+// in place since they are only comments outside of block scalars.
+func (p *yamlParser) split(src string) error {
+    content := false
+    offset := 0
+    for i, raw := range strings.SplitAfter(src, "\n") {
+        start := offset
+        offset += len(raw)
+        raw = strings.TrimRight(raw, "\r\n")
+        number := i + 1
+        indent := 0
+        for indent < len(raw) && raw[indent] == ' ' {
+            indent++
+        }
+        text := raw[indent:]
+        if strings.HasPrefix(text, "\t") {
+            return yamlError(number, indent+1, "tabs are not allowed in indentation")
+        }
+        if i == 0 && strings.HasPrefix(text, "\ufeff") {
+            text = text[len("\ufeff"):]
+            start += len("\ufeff")
+        }
+        if indent == 0 && (strings.HasPrefix(text, "---") || strings.HasPrefix(text, "...")) {
+            if rest := strings.TrimSpace(text[3:]); rest == "" || rest[0] == '#' {
+                if content && text[0] == '-' {
+                    return yamlError(number, 1, "multiple documents are not supported")
+                }
+                continue
+            }
+        }
+        if t := strings.TrimSpace(text); t != "" && t[0] != '#' {
+            content = true
+        }
+        p.lines = append(p.lines, yamlLine{number: number, indent: indent, text: text, start: start, end: offset})
+    }
+    return nil
+}
+
+// next returns the next significant line, skipping blank and comment lines.
+func (p *yamlParser) next() (yamlLine, bool) {
+    for p.pos < len(p.lines) {
+        l := p.lines[p.pos]
+        t := strings.TrimSpace(l.text)
+        if t != "" && t[0] != '#' {
+            return l, true
+        }
+        p.pos++
+    }
+    return yamlLine{}, false
+}
+
+// consume moves past line l.
+func (p *yamlParser) consume(l yamlLine) {
+    p.pos++
+    p.lastEnd = l.end
+}
+
+func (p *yamlParser) add(path []string, value string, line, column int, span [2]int) error {
+    name := strings.Join(path, ".")
+    if prev, exists := p.keys[name]; exists {
+        return yamlError(line, column, "key %s already defined on line %d", name, prev)
+    }
+    p.keys[name] = line
+    p.entries = append(p.entries, Entry{Name: name, Value: value, Line: line})
+    p.spans = append(p.spans, span)
+    return nil
+}
+
+// parseMapping reads the block mapping whose keys are at indent.
+func (p *yamlParser) parseMapping(indent int, path []string) error {
+    for {
+        l, ok := p.next()
+        if !ok || l.indent < indent {
+            p.mappings[strings.Join(path, ".")] = yamlMapping{indent: indent, end: p.lastEnd}
+            return nil
+        }
+        if l.indent > indent {
+            return yamlError(l.number, l.indent+1, "unexpected indentation")
+        }
+        if strings.HasPrefix(l.text, "- ") || l.text == "-" {
+            return yamlError(l.number, l.indent+1, "expected a mapping key but found a sequence item")
+        }
+
+        key, rest, err := yamlKey(l)
+        if err != nil {
+            return err
+        }
+        p.consume(l)
+
+        keyPath := append(append([]string{}, path...), key)
+        valueColumn := l.indent + len(l.text) - len(strings.TrimLeft(rest, " ")) + 1
+        if err := p.parseValue(l, indent, keyPath, rest, valueColumn); err != nil {
+            return err
+        }
+    }
+}
+
+// parseValue reads the value of the mapping key on line l.  The value is
+// either the rest of the line or a nested block on the lines that follow.
+func (p *yamlParser) parseValue(l yamlLine, indent int, path []string, rest string, column int) error {
+    colon := l.start + l.indent + len(l.text) - len(rest)
+    multiline := [2]int{-1, -1}
+
+    rest = strings.TrimSpace(yamlStripComment(rest))
+    if rest != "" && (rest[0] == '|' || rest[0] == '>') {
+        value, err := p.parseBlockScalar(l, indent, rest, column)
+        if err != nil {
+            return err
+        }
+        return p.add(path, value, l.number, column, multiline)
+    }
+    if rest != "" {
+        value, err := yamlScalar(rest, l.number, column)
+        if err != nil {
+            return err
+        }
+        start := l.start + column - 1
+        return p.add(path, value, l.number, column, [2]int{start, start + len(rest)})
+    }
+
+    child, ok := p.next()
+    switch {
+    case ok && child.indent >= indent && (strings.HasPrefix(child.text, "- ") || child.text == "-"):
+        // Sequences may be indented at the same level as their key.
+        value, err := p.parseSequence(child.indent)
+        if err != nil {
+            return err
+        }
+        return p.add(path, value, l.number, column, multiline)
+    case ok && child.indent > indent:
+        return p.parseMapping(child.indent, path)
+    }
+    return p.add(path, "", l.number, column, [2]int{colon, colon})
+}
+
+// parseSequence reads a block sequence of scalars at indent and joins the
+// items with commas.
+func (p *yamlParser) parseSequence(indent int) (string, error) {
+    var items []string
+    for {
+        l, ok := p.next()
+        if !ok || l.indent < indent {
+            break
+        }
+        if l.indent > indent {
+            return "", yamlError(l.number, l.indent+1, "unexpected indentation")
+        }
+        if !strings.HasPrefix(l.text, "- ") && l.text != "-" {
+            break
+        }
+        p.consume(l)
+
+        item := strings.TrimSpace(yamlStripComment(l.text[1:]))
+        column := l.indent + len(l.text) - len(strings.TrimLeft(l.text[1:], " ")) + 1
+        if strings.HasPrefix(item, "- ") || item == "-" {
+            return "", yamlError(l.number, column, "nested sequences are not supported")
+        }
+        if _, _, err := yamlKey(yamlLine{number: l.number, indent: column - 1, text: item}); err == nil {
+            return "", yamlError(l.number, column, "mappings inside sequences are not supported")
+        }
+        value, err := yamlScalar(item, l.number, column)
+        if err != nil {
+            return "", err
+        }
+        items = append(items, value)
+    }
+    return strings.Join(items, ","), nil
+}
+
+// parseBlockScalar reads a literal (|) or folded (>) block scalar whose
+// content is indented more than the key at indent.
+func (p *yamlParser) parseBlockScalar(l yamlLine, indent int, header string, column int) (string, error) {
+    style, chomp := header[0], header[1:]
+    if chomp != "" && chomp != "-" && chomp != "+" {
+        return "", yamlError(l.number, column+1, "unsupported block scalar indicator %q", chomp)
+    }
+
+    var lines []string
+    blockIndent := -1
+    for p.pos < len(p.lines) {
+        next := p.lines[p.pos]
+        if strings.TrimSpace(next.text) == "" {
+            lines = append(lines, "")
+            p.pos++
+            continue
+        }
+        if next.indent <= indent || (blockIndent >= 0 && next.indent < blockIndent) {
+            break
+        }
+        if blockIndent < 0 {
+            blockIndent = next.indent
+        }
+        lines = append(lines, strings.Repeat(" ", next.indent-blockIndent)+next.text)
+        p.consume(next)
+    }
+
+    // Trailing blank lines belong to the surrounding document.
+    trailing := 0
+    for len(lines) > 0 && lines[len(lines)-1] == "" {
+        lines = lines[:len(lines)-1]
+        trailing++
+    }
+    if trailing > 0 {
+        p.pos -= trailing
+    }
+
+    var value string
+    if style == '|' {
+        value = strings.Join(lines, "\n")
+    } else {
+        var b strings.Builder
+        for i, line := range lines {
+            if i > 0 {
+                prev := lines[i-1]
+                switch {
+                case line == "":
+                    b.WriteString("\n")
+                case prev == "":
+                    // The blank lines have already been written as newlines.
+                case strings.HasPrefix(line, " ") || strings.HasPrefix(prev, " "):
+                    b.WriteString("\n")
+                default:
+                    b.WriteString(" ")
+                }
+            }
+            b.WriteString(line)
+        }
+        value = b.String()
+    }
+
+    switch chomp {
+    case "":
+        if value != "" {
+            value += "\n"
+        }
+    case "+":
+        value += strings.Repeat("\n", trailing+1)
+    }
+    return value, nil
+}
+
+// yamlKey splits a "key: value" line into its key and the remaining text.
+func yamlKey(l yamlLine) (string, string, error) {
+    text := l.text
+    if text == "" {
+        return "", "", yamlError(l.number, l.indent+1, "expected a mapping key")
+    }
+
+    var key, rest string
+    if text[0] == '"' || text[0] == '\'' {
+        end := yamlQuoteEnd(text)
+        if end < 0 {
+            return "", "", yamlError(l.number, l.indent+1, "unterminated quoted key")
+        }
+        k, err := yamlScalar(text[:end+1], l.number, l.indent+1)
+        if err != nil {
+            return "", "", err
+        }
+        key, rest = k, text[end+1:]
+        if !strings.HasPrefix(rest, ":") {
+            return "", "", yamlError(l.number, l.indent+end+2, "expected ':' after key")
+        }
+        rest = rest[1:]
+    } else {
+        i := strings.Index(text, ": ")
+        if i < 0 && strings.HasSuffix(text, ":") {
+            i = len(text) - 1
+        }
+        if i < 0 || strings.Contains(text[:i], " #") {
+            return "", "", yamlError(l.number, l.indent+1, "expected 'key: value'")
+        }
+        key, rest = strings.TrimSpace(text[:i]), text[i+1:]
+        if strings.ContainsAny(key[:1], "[]{}&*!|>%@`,?") {
+            return "", "", yamlError(l.number, l.indent+1, "unsupported key %s", key)
+        }
+    }
+    if rest != "" && rest[0] != ' ' {
+        return "", "", yamlError(l.number, l.indent+len(text)-len(rest)+1, "expected a space after ':'")
+    }
+    return key, rest, nil
+}
+
+// yamlQuoteEnd returns the index of the quote which closes the quoted string
+// at the start of s, or -1 if it is not closed.
+func yamlQuoteEnd(s string) int {
+    q := s[0]
+    for i := 1; i < len(s); i++ {
+        switch {
+        case q == '"' && s[i] == '\\':
+            i++
+        case q == '\'' && s[i] == '\'' && i+1 < len(s) && s[i+1] == '\'':
+            i++
+        case s[i] == q:
+            return i
+        }
+    }
+    return -1
+}
+
+// yamlStripComment removes a trailing comment from s.  A comment starts with
+// a '#' at the start of s or after whitespace, outside of any quoted string.
+func yamlStripComment(s string) string {
+    for i := 0; i < len(s); i++ {
+        switch {
+        case s[i] == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+            return s[:i]
+        case (s[i] == '"' || s[i] == '\'') && (i == 0 || strings.IndexByte(" \t[,", s[i-1]) >= 0):
+            if end := yamlQuoteEnd(s[i:]); end >= 0 {
+                i += end
+            }
+        }
+    }
+    return s
+}
+
+// yamlScalar converts a scalar or flow sequence to its value.
+func yamlScalar(s string, line, column int) (string, error) {
+    s = strings.TrimSpace(s)
+    if s == "" {
+        return "", nil
+    }
+    switch s[0] {
+    case '"':
+        end := yamlQuoteEnd(s)
+        if end < 0 {
+            return "", yamlError(line, column, "unterminated string")
+        }
+        if rest := strings.TrimSpace(s[end+1:]); rest != "" {
+            return "", yamlError(line, column+end+1, "unexpected text after string")
+        }
+        return yamlUnescape(s[1:end], line, column)
+    case '\'':
+        end := yamlQuoteEnd(s)
+        if end < 0 {
+            return "", yamlError(line, column, "unterminated string")
+        }
+        if rest := strings.TrimSpace(s[end+1:]); rest != "" {
+            return "", yamlError(line, column+end+1, "unexpected text after string")
+        }
+        return strings.Replace(s[1:end], "''", "'", -1), nil
+    case '[':
+        return yamlFlowSequence(s, line, column)
+    case '{':
+        return "", yamlError(line, column, "flow mappings are not supported")
+    case '&', '*', '!':
+        return "", yamlError(line, column, "anchors, aliases and tags are not supported")
+    case '|', '>', '%', '@', '`':
+        return "", yamlError(line, column, "unexpected %q", s[0])
+    }
+    if s == "~" || s == "null" || s == "Null" || s == "NULL" {
+        return "", nil
+    }
+    return s, nil
+}
+
+// yamlFlowSequence converts a flow sequence such as [a, "b", c] to a comma
+// separated value.
+func yamlFlowSequence(s string, line, column int) (string, error) {
+    if !strings.HasSuffix(s, "]") {
+        return "", yamlError(line, column, "unterminated flow sequence")
+    }
+    inner := s[1 : len(s)-1]
+    var items []string
+    start := 0
+    for i := 0; i <= len(inner); i++ {
+        if i < len(inner) && (inner[i] == '"' || inner[i] == '\'') {
+            end := yamlQuoteEnd(inner[i:])
+            if end < 0 {
+                return "", yamlError(line, column+i+1, "unterminated string")
+            }
+            i += end
+            continue
+        }
+        if i < len(inner) && (inner[i] == '[' || inner[i] == '{') {
+            return "", yamlError(line, column+i+1, "nested collections are not supported")
+        }
+        if i == len(inner) || inner[i] == ',' {
+            item := strings.TrimSpace(inner[start:i])
+            if item == "" && i == len(inner) && len(items) > 0 {
+                break
+            }
+            if item == "" && !(i == len(inner) && len(items) == 0) {
+                return "", yamlError(line, column+i+1, "empty item in flow sequence")
+            }
+            if item != "" {
+                value, err := yamlScalar(item, line, column+start+1)
+                if err != nil {
+                    return "", err
+                }
+                items = append(items, value)
+            }
+            start = i + 1
+        }
+    }
+    return strings.Join(items, ","), nil
+}
+
+// yamlUnescape processes the escape sequences of a double quoted string.
+func yamlUnescape(s string, line, column int) (string, error) {
+    var b strings.Builder
+    for i := 0; i < len(s); i++ {
+        if s[i] != '\\' {
+            b.WriteByte(s[i])
+            continue
+        }
+        i++
+        if i >= len(s) {
+            return "", yamlError(line, column+i, "unterminated escape sequence")
+        }
+        switch s[i] {
+        case '0':
+            b.WriteByte(0)
+        case 'a':
+            b.WriteByte('\a')
+        case 'b':
+            b.WriteByte('\b')
+        case 't', '\t':
+            b.WriteByte('\t')
+        case 'n':
+            b.WriteByte('\n')
+        case 'v':
+            b.WriteByte('\v')
+        case 'f':
+            b.WriteByte('\f')
+        case 'r':
+            b.WriteByte('\r')
+        case 'e':
+            b.WriteByte(0x1b)
+        case ' ', '"', '/', '\\':
+            b.WriteByte(s[i])
+        case 'x', 'u', 'U':
+            n := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[i]]
+            if i+n >= len(s) {
+                return "", yamlError(line, column+i, "invalid escape sequence")
+            }
+            r, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+            if err != nil || !utf8.ValidRune(rune(r)) {
+                return "", yamlError(line, column+i, "invalid escape sequence")
+            }
+            b.WriteRune(rune(r))
+            i += n
+        default:
+            return "", yamlError(line, column+i, "invalid escape sequence \\%c", s[i])
+        }
+    }
+    return b.String(), nil
+}
+
+// writeYAML writes configs as a YAML document.  Dotted item names are written
+// as nested mappings and every item is preceded by its usage text as a
+// comment.
+func writeYAML(w io.Writer, configs []*Configo) error {
+    names := make([]string, len(configs))
+    for i, config := range configs {
+        names[i] = config.Name
+    }
+    return writeYAMLLevel(w, configs, names, 0)
+}
+
+// writeYAMLLevel writes configs, whose names relative to the current mapping
+// are given by names, at the given indentation.  The configs are grouped by
+// the first part of their names, in the order the groups first appear, so
+// that each key is only written once even when names such as "a-b" sort
+// between "a" and "a.b".
+func writeYAMLLevel(w io.Writer, configs []*Configo, names []string, indent int) error {
+    pad := strings.Repeat("  ", indent)
+    var heads []string
+    groups := make(map[string][]int)
+    for i, name := range names {
+        head := strings.SplitN(name, ".", 2)[0]
+        if _, ok := groups[head]; !ok {
+            heads = append(heads, head)
+        }
+        groups[head] = append(groups[head], i)
+    }
+
+    for _, head := range heads {
+        group := groups[head]
+        nested := true
+        for _, k := range group {
+            nested = nested && names[k] != head
+        }
+
+        if nested {
+            if _, err := fmt.Fprintf(w, "%s%s:\n", pad, yamlString(head, true)); err != nil {
+                return err
+            }
+            sub := make([]*Configo, len(group))
+            rest := make([]string, len(group))
+            for n, k := range group {
+                sub[n] = configs[k]
+                rest[n] = names[k][len(head)+1:]
+            }
+            if err := writeYAMLLevel(w, sub, rest, indent+1); err != nil {
+                return err
+            }
+        } else {
+            for _, k := range group {
+                config := configs[k]
+                _, err := fmt.Fprintf(w, "%s# %s\n%s%s: %s\n\n", pad, config.Usage, pad, yamlString(names[k], true), yamlValue(config, config.DefaultValue))
+                if err != nil {
+                    return err
+                }
+            }
+        }
+    }
+    return nil
+}
+
+// yamlValue renders value, a value of config, leaving the built in scalar
+// types unquoted.
+func yamlValue(config *Configo, value string) string {
+    switch config.Value.(type) {
+    case *boolValue, *intValue, *int64Value, *uintValue, *uint64Value, *float64Value:
+        return value
+    }
+    return yamlString(value, false)
+}
+
+// yamlString returns s as a double quoted YAML string.  Keys are only quoted
+// when they would not be read back as written.
+func yamlString(s string, key bool) string {
+    if key && s != "" && !strings.ContainsAny(s, ":#'\"[]{}&*!|>%@`, \t\n\\") && s[0] != '-' && s[0] != '?' {
+        return s
+    }
+    var b strings.Builder
+    b.WriteByte('"')
+    for _, r := range s {
+        switch r {
+        case '"':
+            b.WriteString(`\"`)
+        case '\\':
+            b.WriteString(`\\`)
+        case '\n':
+            b.WriteString(`\n`)
+        case '\t':
+            b.WriteString(`\t`)
+        default:
+            if r < 0x20 || r == 0x7f {
+                fmt.Fprintf(&b, `\x%02X`, r)
+            } else {
+                b.WriteRune(r)
+            }
+        }
+    }
+    b.WriteByte('"')
+    return b.String()
+}
@@ -0,0 +1,180 @@
+package configo
+
+import (
+    "bytes"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestYAMLDecode(t *testing.T) {
+    tests := []struct {
+        desc string
+        src  string
+        want []Entry
+    }{
+        {"plain scalars", "port: 8080\nname: gopher\n", []Entry{{"port", "8080", 1}, {"name", "gopher", 2}}},
+        {"double quoted", `name: "a \"b\" \\ c"`, []Entry{{"name", `a "b" \ c`, 1}}},
+        {"escapes", `s: "tab\there\n\x41\u00e9\U0001F600"`, []Entry{{"s", "tab\there\nA\u00e9\U0001F600", 1}}},
+        {"single quoted", `s: 'it''s \n # not a comment'`, []Entry{{"s", `it's \n # not a comment`, 1}}},
+        {"quoted key", `"my key": 1` + "\n" + `'a.b': 2`, []Entry{{"my key", "1", 1}, {"a.b", "2", 2}}},
+        {"inline comment", "port: 80 # the port\nurl: http://x/#y\nname: \"a # b\" # c\n",
+            []Entry{{"port", "80", 1}, {"url", "http://x/#y", 2}, {"name", "a # b", 3}}},
+        {"comment lines", "# header\n\n  # indented\nport: 80\n", []Entry{{"port", "80", 4}}},
+        {"nested mappings", "db:\n  host: h\n  replica:\n    host: r\ntop: 1\n",
+            []Entry{{"db.host", "h", 2}, {"db.replica.host", "r", 4}, {"top", "1", 5}}},
+        {"null and empty", "a:\nb: ~\nc: null\nd: \"\"\n", []Entry{{"a", "", 1}, {"b", "", 2}, {"c", "", 3}, {"d", "", 4}}},
+        {"block sequence", "ports:\n  - 1\n  - \"2\" # two\n  -   3\n", []Entry{{"ports", "1,2,3", 1}}},
+        {"unindented sequence", "names:\n- a\n- b\nnext: 1\n", []Entry{{"names", "a,b", 1}, {"next", "1", 4}}},
+        {"flow sequence", "names: [a, \"b, c\", 'd']\n", []Entry{{"names", "a,b, c,d", 1}}},
+        {"empty flow sequence", "names: []\n", []Entry{{"names", "", 1}}},
+        {"flow sequence with trailing comma", "names: [a, b,]\n", []Entry{{"names", "a,b", 1}}},
+        {"literal block", "s: |\n  line1\n    line2\n\nn: 1\n", []Entry{{"s", "line1\n  line2\n", 1}, {"n", "1", 5}}},
+        {"folded block", "s: >-\n  one\n  two\n\n  three\n", []Entry{{"s", "one two\nthree", 1}}},
+        {"keep block", "s: |+\n  a\n\nn: 1\n", []Entry{{"s", "a\n\n", 1}, {"n", "1", 4}}},
+        {"document markers", "---\nport: 1\n...\n", []Entry{{"port", "1", 2}}},
+        {"CRLF", "a: 1\r\nb: x\r\n", []Entry{{"a", "1", 1}, {"b", "x", 2}}},
+        {"byte order mark", "\ufeffa: 1\n", []Entry{{"a", "1", 1}}},
+        {"empty", "", nil},
+    }
+    for _, test := range tests {
+        got, err := yamlFormat{}.Decode(strings.NewReader(test.src))
+        if err != nil {
+            t.Errorf("%s: %v", test.desc, err)
+            continue
+        }
+        if !reflect.DeepEqual(got, test.want) {
+            t.Errorf("%s: got %+v, want %+v", test.desc, got, test.want)
+        }
+    }
+}
+
+func TestYAMLDecodeErrors(t *testing.T) {
+    tests := []struct {
+        desc string
+        src  string
+        want string
+    }{
+        {"duplicate key", "a: 1\na: 2\n", "line 2, column 4: key a already defined on line 1"},
+        {"duplicate nested key", "a:\n  b: 1\na.b: 2\n", "line 3, column 6: key a.b already defined on line 2"},
+        {"tab indentation", "a:\n\tb: 1\n", "line 2, column 1: tabs are not allowed in indentation"},
+        {"bad indentation", "a: 1\n  b: 2\n", "line 2, column 3: unexpected indentation"},
+        {"missing colon", "a 1\n", "line 1, column 1: expected 'key: value'"},
+        {"missing space", "a:1\n", "line 1, column 1: expected 'key: value'"},
+        {"top level sequence", "- a\n", "line 1, column 1: the document must be a mapping"},
+        {"sequence in mapping", "a:\n  b: 1\n  - c\n", "line 3, column 3: expected a mapping key but found a sequence item"},
+        {"unterminated string", "a: \"x\n", "line 1, column 4: unterminated string"},
+        {"unterminated key", "\"a: 1\n", "line 1, column 1: unterminated quoted key"},
+        {"text after string", "a: \"x\" y\n", "line 1, column 7: unexpected text after string"},
+        {"bad escape", `a: "\q"`, `line 1, column 5: invalid escape sequence \q`},
+        {"unterminated flow sequence", "a: [1, 2\n", "line 1, column 4: unterminated flow sequence"},
+        {"empty flow item", "a: [1, , 2]\n", "line 1, column 8: empty item in flow sequence"},
+        {"nested flow sequence", "a: [1, [2]]\n", "line 1, column 8: nested collections are not supported"},
+        {"nested sequence", "a:\n  - - 1\n", "line 2, column 5: nested sequences are not supported"},
+        {"mapping in sequence", "a:\n  - b: 1\n", "line 2, column 5: mappings inside sequences are not supported"},
+        {"flow mapping", "a: {b: 1}\n", "line 1, column 4: flow mappings are not supported"},
+        {"anchor", "a: &x 1\n", "line 1, column 4: anchors, aliases and tags are not supported"},
+        {"block indicator", "a: |2\n  x\n", `line 1, column 5: unsupported block scalar indicator "2"`},
+        {"multiple documents", "a: 1\n---\nb: 2\n", "line 2, column 1: multiple documents are not supported"},
+    }
+    for _, test := range tests {
+        _, err := yamlFormat{}.Decode(strings.NewReader(test.src))
+        if err == nil || err.Error() != test.want {
+            t.Errorf("%s: error %v, want %q", test.desc, err, test.want)
+        }
+    }
+}
+
+func TestYAMLEncode(t *testing.T) {
+    c := NewConfigoSet("test", 0, "")
+    c.IntConfig("a", 1, "the a")
+    c.IntConfig("a-b", 3, "the a-b")
+    c.StringConfig("a.b", "z", "the a.b")
+    c.StringConfig("b.c", "x: y", "the b.c")
+    c.IntConfig("b-d", 2, "the b-d")
+    c.StringConfig("b.e.f", "#", "the b.e.f")
+    c.BoolConfig("b.g", true, "the b.g")
+
+    var b bytes.Buffer
+    if err := (yamlFormat{}).Encode(&b, c.fileConfigs(false)); err != nil {
+        t.Fatal(err)
+    }
+    for _, key := range []string{"a:", "b:"} {
+        if n := strings.Count("\n"+b.String(), "\n"+key); n != 1 {
+            t.Errorf("%s is written %d times:\n%s", key, n, b.String())
+        }
+    }
+
+    entries, err := yamlFormat{}.Decode(&b)
+    if err != nil {
+        t.Fatal(err)
+    }
+    got := make(map[string]string)
+    for _, e := range entries {
+        got[e.Name] = e.Value
+    }
+    want := map[string]string{"a": "1", "a-b": "3", "a.b": "z", "b.c": "x: y", "b-d": "2", "b.e.f": "#", "b.g": "true"}
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("read back %q, want %q", got, want)
+    }
+}
+
+func TestYAMLEdit(t *testing.T) {
+    c := NewConfigoSet("test", 0, "")
+    c.IntConfig("port", 80, "the port")
+    c.StringConfig("name", "", "the name")
+    c.StringConfig("db.host", "", "the database host")
+    c.StringConfig("db.user", "", "the database user")
+    c.StringConfig("db.replica.host", "", "the replica host")
+
+    tests := []struct {
+        desc  string
+        src   string
+        name  string
+        value string
+        want  string
+    }{
+        {"replace keeps comments", "# the port\nport: 80 # inline\n", "port", "90", "# the port\nport: 90 # inline\n"},
+        {"replace nested", "db:\n  host: h # main\n  user: u\n", "db.host", "x", "db:\n  host: \"x\" # main\n  user: u\n"},
+        {"replace empty", "name:\nport: 1\n", "name", "n", "name: \"n\"\nport: 1\n"},
+        {"quote string", "name: a\n", "name", "say \"hi\"\n", "name: \"say \\\"hi\\\"\\n\"\n"},
+        {"add to root", "# top\nport: 80\n", "name", "n", "# top\nport: 80\nname: \"n\"\n"},
+        {"add to mapping", "db:\n  host: h\n\n# end\nport: 1\n", "db.user", "u", "db:\n  host: h\n  user: \"u\"\n\n# end\nport: 1\n"},
+        {"add mappings", "port: 80\n", "db.replica.host", "r", "port: 80\ndb:\n  replica:\n    host: \"r\"\n"},
+        {"add without newline", "port: 80", "name", "n", "port: 80\nname: \"n\"\n"},
+        {"empty", "", "port", "90", "port: 90\n"},
+    }
+    for _, test := range tests {
+        got, err := yamlFormat{}.Edit([]byte(test.src), c.Lookup(test.name), test.value)
+        if err != nil {
+            t.Errorf("%s: %v", test.desc, err)
+            continue
+        }
+        if string(got) != test.want {
+            t.Errorf("%s: got %q, want %q", test.desc, got, test.want)
+            continue
+        }
+
+        // The edited document holds the new value.
+        entries, err := parseYAML(got)
+        if err != nil {
+            t.Errorf("%s: edited document: %v", test.desc, err)
+            continue
+        }
+        value := ""
+        for _, e := range entries {
+            if e.Name == test.name {
+                value = e.Value
+            }
+        }
+        if value != test.value {
+            t.Errorf("%s: edited value %q, want %q", test.desc, value, test.value)
+        }
+    }
+
+    for _, src := range []string{"name: |\n  a\n  b\n", "port: [1\n"} {
+        if _, err := (yamlFormat{}).Edit([]byte(src), c.Lookup("name"), "x"); err == nil {
+            t.Errorf("Edit of %q succeeded", src)
+        }
+    }
+}